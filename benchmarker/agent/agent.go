@@ -0,0 +1,99 @@
+// Package agent restarts a team's app between the bench and the persistence
+// check. The agent runs on the team's server and the benchmarker calls it.
+//
+//	POST /restart    restarts the webapp and MySQL, 200 once they were restarted
+//
+// The agent answers 409 while a restart is running and 500 with the command
+// output when the restart failed.
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yahoojapan/yisucon/benchmarker/failure"
+	"github.com/yahoojapan/yisucon/benchmarker/session"
+)
+
+// TokenHeader carries the shared secret of the agent
+const TokenHeader = "X-Restart-Token"
+
+// Restart asks the agent listening on port of the target host to restart the
+// app and blocks until it answered
+func Restart(ctx context.Context, target string, port int, token string) error {
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s/restart", agentAddr(target, port)), nil)
+	if err != nil {
+		return err
+	}
+
+	if len(token) != 0 {
+		req.Header.Set(TokenHeader, token)
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+// WaitReady polls GET / of the target every interval until the app answers
+// 200. It does not use /initialize since that drops the data of the bench.
+func WaitReady(ctx context.Context, target string, interval time.Duration) error {
+	tran := session.NewTransport()
+	defer tran.CloseIdleConnections()
+
+	client := &http.Client{
+		Transport: tran,
+		Timeout:   interval,
+	}
+
+	scheme, host := session.ParseTarget(target)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s://%s/", scheme, host), nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.Do(req.WithContext(ctx))
+		if err == nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return failure.New(failure.Timeout, "", "agent.not_ready")
+		case <-ticker.C:
+		}
+	}
+}
+
+// agentAddr replaces the port of the target host with the one of the agent
+func agentAddr(target string, port int) string {
+	_, host := session.ParseTarget(target)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return net.JoinHostPort(host, fmt.Sprint(port))
+}
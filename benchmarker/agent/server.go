@@ -0,0 +1,94 @@
+package agent
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/yahoojapan/yisucon/benchmarker/logger"
+)
+
+// Server runs command on POST /restart. It is the team side of Restart.
+type Server struct {
+	command string
+	token   string
+	timeout time.Duration
+	l       *sync.Mutex
+	busy    bool
+	log     *logger.Logger
+}
+
+// NewServer returns an agent running command with sh -c for at most timeout
+// on the requests carrying token, which must not be empty.
+func NewServer(command, token string, timeout time.Duration) *Server {
+	return &Server{
+		command: command,
+		token:   token,
+		timeout: timeout,
+		l:       new(sync.Mutex),
+		log:     logger.GetLogger(),
+	}
+}
+
+// Serve listens on addr until it fails
+func (s *Server) Serve(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/restart", s)
+	return http.ListenAndServe(addr, mux)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(TokenHeader)), []byte(s.token)) != 1 {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	// a second benchmarker must not restart the app under the first one
+	if !s.acquire() {
+		http.Error(w, "restart in progress", http.StatusConflict)
+		return
+	}
+	defer s.release()
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	out, err := exec.CommandContext(ctx, "sh", "-c", s.command).CombinedOutput()
+	if err != nil {
+		s.log.Errorf("agent : restart failed : %v\n%s", err, out)
+		http.Error(w, string(out), http.StatusInternalServerError)
+		return
+	}
+
+	s.log.Infof("agent : restarted in %s", time.Since(start))
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) acquire() bool {
+	defer s.l.Unlock()
+	s.l.Lock()
+
+	if s.busy {
+		return false
+	}
+
+	s.busy = true
+	return true
+}
+
+func (s *Server) release() {
+	defer s.l.Unlock()
+	s.l.Lock()
+	s.busy = false
+}
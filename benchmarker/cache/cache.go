@@ -1,7 +1,9 @@
 package cache
 
 import (
+	"bytes"
 	"errors"
+	"io/ioutil"
 	"net/http"
 	"regexp"
 	"strconv"
@@ -10,44 +12,103 @@ import (
 	"time"
 )
 
+// Cache is the HTTP cache of a session. Entries are keyed by method and URL
+// and told apart by the request headers named in Vary.
 type Cache struct {
 	l    *sync.RWMutex
-	Data map[*http.Request]*CacheData
+	Data map[string][]*CacheData
+	// Shared makes the cache act as a proxy: private responses are not
+	// stored and s-maxage overrides max-age
+	Shared bool
 }
 
 type CacheData struct {
 	LastModified string
 	Etag         string
 	Expires      time.Time
-	Resp         *http.Response
+	// NoCache entries are revalidated before each use
+	NoCache bool
+	// Vary holds the request headers the response varies on and their values
+	Vary map[string]string
+
+	Status int
+	Header http.Header
+	Body   []byte
 }
 
 var cacheRegex = regexp.MustCompile(`([a-zA-Z][a-zA-Z_-]*)\s*(?:=(?:"([^"]*)"|([^ \t",;]*)))?`)
 
-func (c *Cache) Get(key *http.Request) (*CacheData, bool) {
+// Key returns the cache key of req
+func Key(req *http.Request) string {
+	return req.Method + " " + req.URL.String()
+}
+
+// Cacheable reports whether responses to req may be stored
+func Cacheable(req *http.Request) bool {
+	return req.Method == http.MethodGet || req.Method == http.MethodHead
+}
+
+// Get returns the entry stored for req, fresh or not. A stale entry with a
+// validator can still be revalidated with Conditional.
+func (c *Cache) Get(req *http.Request) (*CacheData, bool) {
 	defer c.l.RUnlock()
 	c.l.RLock()
-	val, ok := c.Data[key]
 
-	if !ok {
-		return nil, false
-	}
-
-	if !val.IsValid() {
-		delete(c.Data, key)
-		return nil, false
+	for _, val := range c.Data[Key(req)] {
+		if val.matches(req) {
+			return val, true
+		}
 	}
 
-	return val, ok
+	return nil, false
 }
 
-func (c *Cache) Set(key *http.Request, val *CacheData) {
+func (c *Cache) Set(req *http.Request, val *CacheData) {
 	defer c.l.Unlock()
 	c.l.Lock()
+
 	if c.Data == nil {
-		c.Data = make(map[*http.Request]*CacheData)
+		return
 	}
-	c.Data[key] = val
+
+	key := Key(req)
+
+	entries := c.Data[key]
+	for i, e := range entries {
+		if e.matches(req) {
+			entries[i] = val
+			return
+		}
+	}
+
+	c.Data[key] = append(entries, val)
+}
+
+// Revalidated refreshes val with the 304 response res of a conditional request
+func (c *Cache) Revalidated(req *http.Request, val *CacheData, res *http.Response) *CacheData {
+	header := make(http.Header, len(val.Header))
+	for k, v := range val.Header {
+		header[k] = v
+	}
+	for k, v := range res.Header {
+		header[k] = v
+	}
+
+	data := &CacheData{
+		Status: val.Status,
+		Header: header,
+		Body:   val.Body,
+		Vary:   val.Vary,
+	}
+
+	if err := data.parse(header, c.Shared); err != nil {
+		// the entry can not be kept but the body still answers this request
+		return data
+	}
+
+	c.Set(req, data)
+
+	return data
 }
 
 func (c *Cache) Clear() {
@@ -57,53 +118,166 @@ func (c *Cache) Clear() {
 }
 
 func (c *CacheData) IsValid() bool {
-	return time.Now().Before(c.Expires)
+	return !c.NoCache && time.Now().Before(c.Expires)
 }
 
-func NewCache() *Cache {
-	return &Cache{
-		l:    new(sync.RWMutex),
-		Data: make(map[*http.Request]*CacheData),
+// Conditional makes req revalidate c
+func (c *CacheData) Conditional(req *http.Request) {
+	if len(c.Etag) != 0 {
+		req.Header.Set("If-None-Match", c.Etag)
+	}
+	if len(c.LastModified) != 0 {
+		req.Header.Set("If-Modified-Since", c.LastModified)
 	}
 }
 
-func NewHTTPCache(res *http.Response) (*CacheData, error) {
+// Response returns a new response to req from c
+func (c *CacheData) Response(req *http.Request) *http.Response {
+	header := make(http.Header, len(c.Header))
+	for k, v := range c.Header {
+		header[k] = v
+	}
 
-	header := res.Header.Get("Cache-Control")
-	if len(header) == 0 {
-		return nil, errors.New("Cache-Control Header Not Found")
+	return &http.Response{
+		Status:        strconv.Itoa(c.Status) + " " + http.StatusText(c.Status),
+		StatusCode:    c.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(c.Body)),
+		ContentLength: int64(len(c.Body)),
+		Request:       req,
 	}
+}
 
-	cache := make(map[string]string)
+func (c *CacheData) matches(req *http.Request) bool {
+	for name, value := range c.Vary {
+		if req.Header.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
 
-	for _, match := range cacheRegex.Copy().FindAllString(header, -1) {
-		if strings.EqualFold(match, "no-store") {
-			return nil, errors.New("no-store detected")
+func NewCache(shared bool) *Cache {
+	return &Cache{
+		l:      new(sync.RWMutex),
+		Data:   make(map[string][]*CacheData),
+		Shared: shared,
+	}
+}
+
+// NewHTTPCache returns the entry of the response res to req with its body
+func NewHTTPCache(req *http.Request, res *http.Response, body []byte, shared bool) (*CacheData, error) {
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.New("status not cacheable")
+	}
+
+	vary := make(map[string]string)
+
+	for _, v := range res.Header["Vary"] {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				return nil, errors.New("Vary * detected")
+			}
+			if len(name) != 0 {
+				vary[http.CanonicalHeaderKey(name)] = req.Header.Get(name)
+			}
 		}
-		var key, value string
-		key = match
-		if index := strings.Index(match, "="); index != -1 {
-			key, value = match[:index], match[index+1:]
+	}
+
+	data := &CacheData{
+		Status: res.StatusCode,
+		Header: res.Header,
+		Body:   body,
+		Vary:   vary,
+	}
+
+	if err := data.parse(res.Header, shared); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// parse sets the validators and the freshness of c from header
+func (c *CacheData) parse(header http.Header, shared bool) error {
+	c.LastModified = header.Get("Last-Modified")
+	c.Etag = header.Get("ETag")
+
+	directives := make(map[string]string)
+
+	for _, v := range header["Cache-Control"] {
+		for _, match := range cacheRegex.FindAllStringSubmatch(v, -1) {
+			value := match[2]
+			if len(value) == 0 {
+				value = match[3]
+			}
+			directives[strings.ToLower(match[1])] = value
 		}
-		cache[key] = value
 	}
 
-	limit, ok := cache["max-age"]
+	if _, ok := directives["no-store"]; ok {
+		return errors.New("no-store detected")
+	}
 
-	if !ok {
-		return nil, errors.New("cache age not found")
+	if _, ok := directives["private"]; ok && shared {
+		return errors.New("private detected")
 	}
 
-	t, err := strconv.Atoi(limit)
+	_, c.NoCache = directives["no-cache"]
 
-	if err != nil {
-		return nil, err
+	validator := len(c.Etag) != 0 || len(c.LastModified) != 0
+
+	age, ok := maxAge(directives, shared)
+
+	switch {
+	case ok:
+		if current, err := strconv.Atoi(header.Get("Age")); err == nil {
+			age -= time.Duration(current) * time.Second
+		}
+		c.Expires = time.Now().Add(age)
+	case len(header.Get("Expires")) != 0:
+		// an invalid Expires such as "0" means already expired
+		expires, err := http.ParseTime(header.Get("Expires"))
+		if err != nil {
+			expires = time.Time{}
+		}
+		if date, err := http.ParseTime(header.Get("Date")); err == nil {
+			expires = time.Now().Add(expires.Sub(date))
+		}
+		c.Expires = expires
+	case !validator:
+		return errors.New("cache age not found")
+	}
+
+	// an entry that can never be reused without a validator is useless
+	if (c.NoCache || !time.Now().Before(c.Expires)) && !validator {
+		return errors.New("no validator for a stale entry")
+	}
+
+	return nil
+}
+
+// maxAge returns the freshness lifetime of the directives, s-maxage first
+// for a shared cache
+func maxAge(directives map[string]string, shared bool) (time.Duration, bool) {
+	keys := []string{"max-age"}
+	if shared {
+		keys = []string{"s-maxage", "max-age"}
+	}
+
+	for _, key := range keys {
+		if v, ok := directives[key]; ok {
+			t, err := strconv.Atoi(v)
+			if err != nil {
+				return 0, true
+			}
+			return time.Duration(t) * time.Second, true
+		}
 	}
 
-	return &CacheData{
-		LastModified: res.Header.Get("Last-Modified"),
-		Etag:         res.Header.Get("ETag"),
-		Expires:      time.Now().Add(time.Duration(t) * time.Second),
-		Resp:         res,
-	}, nil
+	return 0, false
 }
@@ -0,0 +1,184 @@
+package cache
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func response(header map[string]string) *http.Response {
+	res := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+	}
+	for k, v := range header {
+		res.Header.Set(k, v)
+	}
+	return res
+}
+
+func TestNewHTTPCache(t *testing.T) {
+	now := time.Now().UTC()
+
+	tests := []struct {
+		name    string
+		header  map[string]string
+		shared  bool
+		wantErr bool
+		valid   bool
+	}{
+		{"max-age", map[string]string{"Cache-Control": "max-age=60"}, false, false, true},
+		{"max-age quoted", map[string]string{"Cache-Control": `max-age="60"`}, false, false, true},
+		{"age used up", map[string]string{"Cache-Control": "max-age=60", "Age": "60", "ETag": `"a"`}, false, false, false},
+		{"no-store", map[string]string{"Cache-Control": "no-store, max-age=60"}, false, true, false},
+		{"no-cache with validator", map[string]string{"Cache-Control": "no-cache", "ETag": `"a"`}, false, false, false},
+		{"no-cache without validator", map[string]string{"Cache-Control": "no-cache, max-age=60"}, false, true, false},
+		{"private", map[string]string{"Cache-Control": "private, max-age=60"}, false, false, true},
+		{"private shared", map[string]string{"Cache-Control": "private, max-age=60"}, true, true, false},
+		{"s-maxage ignored", map[string]string{"Cache-Control": "s-maxage=60, max-age=0", "ETag": `"a"`}, false, false, false},
+		{"s-maxage shared", map[string]string{"Cache-Control": "s-maxage=60, max-age=0"}, true, false, true},
+		{"expires", map[string]string{"Expires": now.Add(time.Minute).Format(http.TimeFormat), "Date": now.Format(http.TimeFormat)}, false, false, true},
+		{"expires invalid", map[string]string{"Expires": "0", "Last-Modified": now.Format(http.TimeFormat)}, false, false, false},
+		{"validator only", map[string]string{"ETag": `"a"`}, false, false, false},
+		{"nothing", map[string]string{}, false, true, false},
+		{"vary star", map[string]string{"Cache-Control": "max-age=60", "Vary": "*"}, false, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+
+			data, err := NewHTTPCache(req, response(tt.header), []byte("body"), tt.shared)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewHTTPCache() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if data.IsValid() != tt.valid {
+				t.Errorf("IsValid() = %v, want %v", data.IsValid(), tt.valid)
+			}
+		})
+	}
+}
+
+func TestNotCacheableStatus(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+
+	res := response(map[string]string{"Cache-Control": "max-age=60"})
+	res.StatusCode = http.StatusNotFound
+
+	if _, err := NewHTTPCache(req, res, nil, false); err == nil {
+		t.Error("a 404 was cached")
+	}
+}
+
+func TestVary(t *testing.T) {
+	c := NewCache(false)
+
+	get := func(lang string) *http.Request {
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+		if len(lang) != 0 {
+			req.Header.Set("Accept-Language", lang)
+		}
+		return req
+	}
+
+	for _, lang := range []string{"ja", "en"} {
+		req := get(lang)
+		data, err := NewHTTPCache(req, response(map[string]string{"Cache-Control": "max-age=60", "Vary": "Accept-Language"}), []byte(lang), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Set(req, data)
+	}
+
+	tests := []struct {
+		lang string
+		body string
+		ok   bool
+	}{
+		{"ja", "ja", true},
+		{"en", "en", true},
+		{"fr", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		data, ok := c.Get(get(tt.lang))
+		if ok != tt.ok {
+			t.Errorf("Get(%q) found %v, want %v", tt.lang, ok, tt.ok)
+			continue
+		}
+		if ok && string(data.Body) != tt.body {
+			t.Errorf("Get(%q) = %q, want %q", tt.lang, data.Body, tt.body)
+		}
+	}
+}
+
+func TestRevalidated(t *testing.T) {
+	c := NewCache(false)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+
+	data, err := NewHTTPCache(req, response(map[string]string{"Cache-Control": "no-cache", "ETag": `"a"`, "X-Origin": "first"}), []byte("body"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set(req, data)
+
+	cond, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	data.Conditional(cond)
+
+	if got := cond.Header.Get("If-None-Match"); got != `"a"` {
+		t.Errorf("If-None-Match = %q, want %q", got, `"a"`)
+	}
+
+	fresh := c.Revalidated(req, data, &http.Response{
+		StatusCode: http.StatusNotModified,
+		Header:     http.Header{"Cache-Control": {"max-age=60"}, "Etag": {`"b"`}},
+	})
+
+	if string(fresh.Body) != "body" || fresh.Status != http.StatusOK {
+		t.Errorf("Revalidated() = %d %q, want the stored 200 response", fresh.Status, fresh.Body)
+	}
+	if !fresh.IsValid() || fresh.Etag != `"b"` || fresh.Header.Get("X-Origin") != "first" {
+		t.Errorf("Revalidated() did not merge the 304 headers : %+v", fresh)
+	}
+
+	if stored, ok := c.Get(req); !ok || stored != fresh {
+		t.Error("Revalidated() did not replace the stored entry")
+	}
+
+	// a 304 forbidding storage still answers the request but is dropped
+	gone := c.Revalidated(req, fresh, &http.Response{
+		StatusCode: http.StatusNotModified,
+		Header:     http.Header{"Cache-Control": {"no-store"}},
+	})
+
+	if string(gone.Body) != "body" {
+		t.Errorf("Revalidated() = %q, want the stored body", gone.Body)
+	}
+	if stored, _ := c.Get(req); stored != fresh {
+		t.Error("a no-store 304 replaced the stored entry")
+	}
+}
+
+func TestResponse(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+
+	data, err := NewHTTPCache(req, response(map[string]string{"Cache-Control": "max-age=60", "Content-Type": "text/html"}), []byte("body"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := data.Response(req)
+	res.Header.Set("Content-Type", "text/plain")
+
+	if data.Header.Get("Content-Type") != "text/html" {
+		t.Error("Response() shares its header with the cache")
+	}
+	if res.StatusCode != http.StatusOK || res.ContentLength != 4 {
+		t.Errorf("Response() = %d with %d bytes", res.StatusCode, res.ContentLength)
+	}
+}
@@ -1,5 +1,10 @@
 package checker
 
+import (
+	"github.com/yahoojapan/yisucon/benchmarker/failure"
+	"github.com/yahoojapan/yisucon/benchmarker/score"
+)
+
 type Action struct {
 	Action func() (int, error)
 	Name   string
@@ -15,3 +20,32 @@ func NewAction(method, name string, action func() (int, error)) *Action {
 		Method: method,
 	}
 }
+
+// Run scores action and attaches the last request of c to a failure
+func (c *Checker) Run(action *Action) score.Score {
+	c.Session.ResetExchange()
+
+	s := score.CalcScore(action.Method, action.Name, action.Action)
+
+	if s.Error != nil {
+		s.Exchange = c.Session.LastExchange()
+		s.Error = c.withRequest(s.Error)
+	}
+
+	return s
+}
+
+// withRequest gives err the URL and status of the last request when it does
+// not carry them
+func (c *Checker) withRequest(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	ex := c.Session.LastExchange()
+	if ex == nil {
+		return failure.WithRequest(err, "", 0)
+	}
+
+	return failure.WithRequest(err, ex.URL, ex.Status)
+}
@@ -3,17 +3,19 @@ package checker
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
 	"github.com/yahoojapan/yisucon/benchmarker/config"
+	"github.com/yahoojapan/yisucon/benchmarker/failure"
 	"github.com/yahoojapan/yisucon/benchmarker/logger"
 	"github.com/yahoojapan/yisucon/benchmarker/model"
 	"github.com/yahoojapan/yisucon/benchmarker/session"
+	"github.com/yahoojapan/yisucon/benchmarker/stats"
 	"github.com/yahoojapan/yisucon/benchmarker/util"
 	"github.com/PuerkitoBio/goquery"
 )
@@ -23,14 +25,23 @@ type Checker struct {
 	Host    string
 	Session *session.Session
 	Logger  *logger.Logger
+	Rand    *rand.Rand
+	// Journal records the acknowledged writes when not nil
+	Journal *Journal
 }
 
-func NewChecker(ctx context.Context, host string, account *model.Account) *Checker {
+func NewChecker(ctx context.Context, host string, account *model.Account, rec *stats.Recorder, rnd *rand.Rand) *Checker {
+	sess := session.NewSession(ctx, host)
+	sess.Recorder = rec
+	sess.Template = pathTemplate
+	sess.CheckAsset = checkAsset
+
 	return &Checker{
 		Account: account,
-		Host:    host,
-		Session: session.NewSession(ctx, host),
+		Host:    sess.Host,
+		Session: sess,
 		Logger:  logger.GetLogger(),
+		Rand:    rnd,
 	}
 }
 
@@ -48,11 +59,13 @@ func (c *Checker) InitialCheck() (int, error) {
 	errChan := make(chan error, 1)
 	done := make(chan struct{}, 0)
 
+	uri := fmt.Sprintf("http://%s/%s", c.Host, "initialize")
+
 	go func() {
 		defer close(done)
 		defer close(errChan)
 
-		resp, err := c.Session.SendSimpleRequest(http.MethodGet, fmt.Sprintf("http://%s/%s", c.Host, "initialize"), nil)
+		resp, err := c.Session.SendSimpleRequest(http.MethodGet, uri, nil)
 
 		if err != nil {
 			errChan <- err
@@ -60,7 +73,7 @@ func (c *Checker) InitialCheck() (int, error) {
 		}
 
 		if resp == nil || resp.Body == nil {
-			errChan <- errors.New("初期化Responseがnilです")
+			errChan <- failure.New(failure.ContentMismatch, "", "init.nil_response")
 			return
 		}
 
@@ -71,7 +84,7 @@ func (c *Checker) InitialCheck() (int, error) {
 		json.NewDecoder(resp.Body).Decode(&result)
 
 		if val, ok := result["result"]; !ok || !strings.EqualFold(val.(string), "ok") {
-			errChan <- errors.New("初期化処理に失敗しました")
+			errChan <- failure.New(failure.ContentMismatch, "result", "init.failed")
 			return
 		}
 
@@ -81,7 +94,7 @@ func (c *Checker) InitialCheck() (int, error) {
 	select {
 	case <-ctx.Done():
 		//Timeout Failed
-		return -1, errors.New("初期化処理が長すぎます")
+		return -1, failure.Request(uri, ctx.Err(), "init.timeout")
 	case res := <-errChan:
 		return -1, res
 	case <-done:
@@ -98,13 +111,13 @@ func (c *Checker) JSCheck() (int, error) {
 	}
 
 	if resp == nil || resp.Body == nil {
-		return -1, http.ErrBodyNotAllowed
+		return -1, ErrJSNoBody
 	}
 
 	defer resp.Body.Close()
 
 	if util.GetMD5ByIO(resp.Body) != jsMD5 {
-		return -1, errors.New("不正なJavaScriptファイルです")
+		return -1, ErrJSMismatch
 	}
 
 	return 1, nil
@@ -119,13 +132,13 @@ func (c *Checker) CSSCheck() (int, error) {
 	}
 
 	if resp == nil || resp.Body == nil {
-		return -1, http.ErrBodyNotAllowed
+		return -1, ErrCSSNoBody
 	}
 
 	defer resp.Body.Close()
 
 	if util.GetMD5ByIO(resp.Body) != cssMD5 {
-		return -1, errors.New("不正なCSSファイルです")
+		return -1, ErrCSSMismatch
 	}
 
 	return 1, nil
@@ -150,8 +163,8 @@ func (c *Checker) PageLoadCheck() (int, error) {
 	path := []string{
 		"",
 		c.Account.Name,
-		"search?q=" + url.QueryEscape(randomWord()),
-		"hashtag/" + url.QueryEscape(randomWord()),
+		"search?q=" + url.QueryEscape(randomWord(c.Rand)),
+		"hashtag/" + url.QueryEscape(randomWord(c.Rand)),
 	}
 	for _, p := range path {
 		_, err := c.Session.SendSimpleRequest(http.MethodGet, fmt.Sprintf("http://%s/%s", c.Host, p), nil)
@@ -175,13 +188,13 @@ func (c *Checker) MyPageCheck() (int, error) {
 	err = checkHTML(func(doc *goquery.Document) error {
 		text := doc.Find("h3").Text()
 		if text != c.Account.Name+" さんのツイート" {
-			return errors.New("タイトルが不適切です")
+			return failure.New(failure.ContentMismatch, "h3", "mypage.title")
 		}
 		var err error
 		doc.Find(".tweet").EachWithBreak(func(_ int, s *goquery.Selection) bool {
 			name := s.Find(".tweet-user-name").Text()
 			if name != c.Account.Name {
-				err = errors.New("異なるユーザーのツイートが含まれています")
+				err = failure.New(failure.ContentMismatch, ".tweet-user-name", "mypage.other_user")
 				return false
 			}
 			return true
@@ -189,7 +202,7 @@ func (c *Checker) MyPageCheck() (int, error) {
 		return err
 	})(resp.Body)
 	if err != nil {
-		c.Logger.Println(err)
+		c.Logger.Debug(err)
 		return -1, err
 	}
 
@@ -215,7 +228,7 @@ func (c *Checker) FakeLoginCheck() (int, error) {
 	//ログインできないこと
 	resp, err := c.Session.SendFormPost(fmt.Sprintf("http://%s/login", c.Host), map[string]string{
 		"name":     c.Account.Name,
-		"password": randomPass(),
+		"password": randomPass(c.Rand),
 	})
 
 	if err != nil {
@@ -226,14 +239,14 @@ func (c *Checker) FakeLoginCheck() (int, error) {
 	err = checkHTML(func(doc *goquery.Document) error {
 		flush := doc.Find(".flush")
 		if flush.Length() == 0 {
-			return errors.New("ログインエラーが見つかりません")
+			return failure.New(failure.MissingElement, ".flush", "login.no_error")
 		}
 
 		return nil
 	})(resp.Body)
 
 	if err != nil {
-		c.Logger.Println(err)
+		c.Logger.Debug(err)
 		return -1, err
 	}
 
@@ -256,19 +269,19 @@ func (c *Checker) LoginCheck() (int, error) {
 	err = checkHTML(func(doc *goquery.Document) error {
 		login := doc.Find(".login")
 		if login.Length() != 0 {
-			return errors.New("ログイン時にログインフォームが存在します")
+			return failure.New(failure.ContentMismatch, ".login", "login.login_form")
 		}
 		logout := doc.Find(".logout")
 		if logout.Length() == 0 {
-			return errors.New("ログイン時にログアウトボタンが見つかりません")
+			return failure.New(failure.MissingElement, ".logout", "login.no_logout")
 		}
 		name := doc.Find(".name")
 		if name.Text() != "こんにちは "+c.Account.Name+"さん" {
-			return errors.New("ログイン時にユーザー名が見つかりません")
+			return failure.New(failure.ContentMismatch, ".name", "login.no_name")
 		}
 		post := doc.Find(".post")
 		if post.Length() == 0 {
-			return errors.New("ログイン時にツイートフォームが見つかりません")
+			return failure.New(failure.MissingElement, ".post", "login.no_tweet_form")
 		}
 
 		doc.Find(".tweet").EachWithBreak(func(_ int, s *goquery.Selection) bool {
@@ -280,11 +293,11 @@ func (c *Checker) LoginCheck() (int, error) {
 			return true
 		})
 		if _, ok := c.Session.Storage["firstuser"]; !ok {
-			return errors.New("タイムラインに他ユーザーが見つかりません")
+			return failure.New(failure.MissingElement, ".tweet-user-name", "login.no_other_user")
 		}
 		until, ok := doc.Find(".tweet").Last().Attr("data-time")
 		if !ok {
-			return errors.New("data-time属性が見つかりません")
+			return failure.New(failure.MissingElement, ".tweet[data-time]", "tweet.no_time")
 		}
 		c.Session.Storage["until"] = url.QueryEscape(until)
 
@@ -292,10 +305,13 @@ func (c *Checker) LoginCheck() (int, error) {
 	})(resp.Body)
 
 	if err != nil {
-		c.Logger.Println(err)
+		c.Logger.Debug(err)
 		return -1, err
 	}
 
+	c.Session.Storage["login"] = true
+	c.Session.Storage["following"] = true
+
 	return 1, nil
 }
 
@@ -304,7 +320,7 @@ func (c *Checker) PagingCheck() (int, error) {
 	//http://localhost:8080/?append=1&until=time_string
 	until, ok := c.Session.Storage["until"].(string)
 	if !ok {
-		return -1, errors.New("untilパラメータが見つかりません")
+		return -1, failure.New(failure.MissingElement, ".tweet[data-time]", "paging.no_until")
 	}
 
 	resp, err := c.Session.SendSimpleRequest(http.MethodGet, fmt.Sprintf("http://%s/?append=1&until=%s", c.Host, until), nil)
@@ -317,27 +333,27 @@ func (c *Checker) PagingCheck() (int, error) {
 		var err error
 		tweets := doc.Find(".tweet")
 		if tweets.Length() != 50 {
-			return errors.New("表示されているツイートが足りません")
+			return failure.New(failure.MissingElement, ".tweet", "paging.short")
 		}
 
 		until, _ = url.QueryUnescape(until)
 		newer, err := time.Parse("2006-01-02 15:04:05", until)
 		if err != nil {
-			return errors.New("untilパラメータの形式が不適切です")
+			return failure.New(failure.ContentMismatch, ".tweet[data-time]", "paging.bad_until")
 		}
 		tweets.EachWithBreak(func(_ int, s *goquery.Selection) bool {
 			attr, ok := s.Attr("data-time")
 			if !ok {
-				err = errors.New("data-time属性が見つかりません")
+				err = failure.New(failure.MissingElement, ".tweet[data-time]", "tweet.no_time")
 				return false
 			}
 			older, err := time.Parse("2006-01-02 15:04:05", attr)
 			if err != nil {
-				err = errors.New("data-time属性の形式が不適切です")
+				err = failure.New(failure.ContentMismatch, ".tweet[data-time]", "tweet.bad_time")
 				return false
 			}
 			if older.After(newer) {
-				err = errors.New("ツイートの並びが不適切です")
+				err = failure.New(failure.ContentMismatch, ".tweet[data-time]", "paging.order")
 				return false
 			}
 			newer = older
@@ -349,13 +365,13 @@ func (c *Checker) PagingCheck() (int, error) {
 
 		until, ok := tweets.Last().Attr("data-time")
 		if !ok {
-			return errors.New("data-time属性が見つかりません")
+			return failure.New(failure.MissingElement, ".tweet[data-time]", "tweet.no_time")
 		}
 		c.Session.Storage["until"] = url.QueryEscape(until)
 		return nil
 	})(resp.Body)
 	if err != nil {
-		c.Logger.Println(err)
+		c.Logger.Debug(err)
 		return -1, err
 	}
 	return 1, nil
@@ -372,13 +388,13 @@ func (c *Checker) SelfPageCheck() (int, error) {
 	err = checkHTML(func(doc *goquery.Document) error {
 		text := doc.Find(`h4`).Text()
 		if text != "あなたのページです" {
-			return errors.New("ログインユーザーのページが不適切です")
+			return failure.New(failure.ContentMismatch, "h4", "selfpage.title")
 		}
 
 		return nil
 	})(resp.Body)
 	if err != nil {
-		c.Logger.Println(err)
+		c.Logger.Debug(err)
 		return -1, err
 	}
 
@@ -389,7 +405,7 @@ func (c *Checker) UnfollowButtonCheck() (int, error) {
 	//一番上のuser: unfollowボタン
 	firstuser, ok := c.Session.Storage["firstuser"].(string)
 	if !ok {
-		return -1, errors.New("該当するユーザがいません")
+		return -1, failure.New(failure.MissingElement, ".tweet-user-name", "user.missing")
 	}
 
 	resp, err := c.Session.SendSimpleRequest(http.MethodGet, fmt.Sprintf("http://%s/%s", c.Host, firstuser), nil)
@@ -401,13 +417,13 @@ func (c *Checker) UnfollowButtonCheck() (int, error) {
 	err = checkHTML(func(doc *goquery.Document) error {
 		text := doc.Find(`#user-unfollow-button`).Text()
 		if text != "アンフォロー" {
-			return errors.New("アンフォローボタンがありません")
+			return failure.New(failure.MissingElement, "#user-unfollow-button", "unfollow.no_button")
 		}
 
 		return nil
 	})(resp.Body)
 	if err != nil {
-		c.Logger.Println(err)
+		c.Logger.Debug(err)
 		return -1, err
 	}
 
@@ -418,7 +434,7 @@ func (c *Checker) UnfollowCheck() (int, error) {
 	//unfollow
 	firstuser, ok := c.Session.Storage["firstuser"].(string)
 	if !ok {
-		return -1, errors.New("該当するユーザがいません")
+		return -1, failure.New(failure.MissingElement, ".tweet-user-name", "user.missing")
 	}
 
 	resp, err := c.Session.SendFormPost(fmt.Sprintf("http://%s/unfollow", c.Host), map[string]string{
@@ -431,6 +447,9 @@ func (c *Checker) UnfollowCheck() (int, error) {
 
 	defer resp.Body.Close()
 
+	c.Session.Storage["following"] = false
+	c.Journal.add(Write{Kind: WriteUnfollow, Account: c.Account, Target: firstuser})
+
 	return 1, nil
 }
 
@@ -438,7 +457,7 @@ func (c *Checker) RemoveFromTopCheck() (int, error) {
 	// Unfollowしたらtopから消える
 	firstuser, ok := c.Session.Storage["firstuser"].(string)
 	if !ok {
-		return -1, errors.New("該当するユーザがいません")
+		return -1, failure.New(failure.MissingElement, ".tweet-user-name", "user.missing")
 	}
 
 	resp, err := c.Session.SendSimpleRequest(http.MethodGet, fmt.Sprintf("http://%s/", c.Host), nil)
@@ -452,7 +471,7 @@ func (c *Checker) RemoveFromTopCheck() (int, error) {
 		doc.Find(".tweet").EachWithBreak(func(_ int, s *goquery.Selection) bool {
 			name := s.Find(".tweet-user-name").Text()
 			if name == firstuser {
-				err = errors.New("unfollowしたユーザが消えていません")
+				err = failure.New(failure.ContentMismatch, ".tweet-user-name", "unfollow.still_shown")
 				return false
 			}
 			return true
@@ -460,7 +479,7 @@ func (c *Checker) RemoveFromTopCheck() (int, error) {
 		return err
 	})(resp.Body)
 	if err != nil {
-		c.Logger.Println(err)
+		c.Logger.Debug(err)
 		return -1, err
 	}
 
@@ -471,7 +490,7 @@ func (c *Checker) FollowButtonCheck() (int, error) {
 	//userページにfollowボタンが出る
 	firstuser, ok := c.Session.Storage["firstuser"].(string)
 	if !ok {
-		return -1, errors.New("該当するユーザがいません")
+		return -1, failure.New(failure.MissingElement, ".tweet-user-name", "user.missing")
 	}
 
 	resp, err := c.Session.SendSimpleRequest(http.MethodGet, fmt.Sprintf("http://%s/%s", c.Host, firstuser), nil)
@@ -483,13 +502,13 @@ func (c *Checker) FollowButtonCheck() (int, error) {
 	err = checkHTML(func(doc *goquery.Document) error {
 		text := doc.Find(`#user-follow-button`).Text()
 		if text != "フォロー" {
-			return errors.New("フォローボタンがありません")
+			return failure.New(failure.MissingElement, "#user-follow-button", "follow.no_button")
 		}
 
 		return nil
 	})(resp.Body)
 	if err != nil {
-		c.Logger.Println(err)
+		c.Logger.Debug(err)
 		return -1, err
 	}
 	return 1, nil
@@ -499,7 +518,7 @@ func (c *Checker) FollowCheck() (int, error) {
 	//followできる
 	firstuser, ok := c.Session.Storage["firstuser"].(string)
 	if !ok {
-		return -1, errors.New("該当するユーザがいません")
+		return -1, failure.New(failure.MissingElement, ".tweet-user-name", "user.missing")
 	}
 
 	resp, err := c.Session.SendFormPost(fmt.Sprintf("http://%s/follow", c.Host), map[string]string{
@@ -511,6 +530,9 @@ func (c *Checker) FollowCheck() (int, error) {
 	}
 	defer resp.Body.Close()
 
+	c.Session.Storage["following"] = true
+	c.Journal.add(Write{Kind: WriteFollow, Account: c.Account, Target: firstuser})
+
 	return 1, nil
 }
 
@@ -518,7 +540,7 @@ func (c *Checker) FollowerTweetCheck() (int, error) {
 	//topにフォローしたユーザーのtweetが出る(いちばん上じゃない可能性がある)
 	firstuser, ok := c.Session.Storage["firstuser"].(string)
 	if !ok {
-		return -1, errors.New("該当するユーザがいません")
+		return -1, failure.New(failure.MissingElement, ".tweet-user-name", "user.missing")
 	}
 
 	resp, err := c.Session.SendSimpleRequest(http.MethodGet, fmt.Sprintf("http://%s/", c.Host), nil)
@@ -538,12 +560,12 @@ func (c *Checker) FollowerTweetCheck() (int, error) {
 			return true
 		})
 		if !found {
-			return errors.New("followしたユーザが表示されていません")
+			return failure.New(failure.MissingElement, ".tweet-user-name", "follow.not_shown")
 		}
 		return nil
 	})(resp.Body)
 	if err != nil {
-		c.Logger.Println(err)
+		c.Logger.Debug(err)
 		return -1, err
 	}
 
@@ -552,8 +574,8 @@ func (c *Checker) FollowerTweetCheck() (int, error) {
 
 func (c *Checker) HashTagTweetCheck() (int, error) {
 	//post（ハッシュタグ付きのデータ）
-	tweet := "テストツイート" + randomIntString()
-	hashtag := randomWord()
+	tweet := "テストツイート" + randomIntString(c.Rand)
+	hashtag := randomWord(c.Rand)
 	c.Session.Storage["tweet"] = tweet
 	c.Session.Storage["hashtag"] = hashtag
 
@@ -567,17 +589,19 @@ func (c *Checker) HashTagTweetCheck() (int, error) {
 
 	defer resp.Body.Close()
 
+	c.Journal.add(Write{Kind: WriteTweet, Account: c.Account, Text: tweet, Hashtag: hashtag})
+
 	return 1, nil
 }
 func (c *Checker) TweetCheck() (int, error) {
 	//topにtweetが出る(いちばん上じゃない可能性がある)
 	tweet, ok := c.Session.Storage["tweet"].(string)
 	if !ok {
-		return -1, errors.New("投稿したツイートが見つかりません")
+		return -1, failure.New(failure.MissingElement, ".tweet", "tweet.missing")
 	}
 	hashtag, ok := c.Session.Storage["hashtag"].(string)
 	if !ok {
-		return -1, errors.New("投稿したツイートが見つかりません")
+		return -1, failure.New(failure.MissingElement, ".tweet", "tweet.missing")
 	}
 
 	resp, err := c.Session.SendSimpleRequest(http.MethodGet, fmt.Sprintf("http://%s/", c.Host), nil)
@@ -596,12 +620,12 @@ func (c *Checker) TweetCheck() (int, error) {
 			return true
 		})
 		if !found {
-			return errors.New("投稿したツイートが表示されていません")
+			return failure.New(failure.MissingElement, ".tweet", "tweet.not_shown")
 		}
 		return nil
 	})(resp.Body)
 	if err != nil {
-		c.Logger.Println(err)
+		c.Logger.Debug(err)
 		return -1, err
 	}
 
@@ -612,11 +636,11 @@ func (c *Checker) HashTagCheck() (int, error) {
 	//ハッシュタグがリンクになる
 	tweet, ok := c.Session.Storage["tweet"].(string)
 	if !ok {
-		return -1, errors.New("投稿したツイートが見つかりません")
+		return -1, failure.New(failure.MissingElement, ".tweet", "tweet.missing")
 	}
 	hashtag, ok := c.Session.Storage["hashtag"].(string)
 	if !ok {
-		return -1, errors.New("投稿したツイートが見つかりません")
+		return -1, failure.New(failure.MissingElement, ".tweet", "tweet.missing")
 	}
 
 	resp, err := c.Session.SendSimpleRequest(http.MethodGet, fmt.Sprintf("http://%s/hashtag/%s", c.Host, url.QueryEscape(hashtag)), nil)
@@ -635,12 +659,12 @@ func (c *Checker) HashTagCheck() (int, error) {
 			return true
 		})
 		if !found {
-			return errors.New("投稿したツイートが表示されていません")
+			return failure.New(failure.MissingElement, ".tweet", "tweet.not_shown")
 		}
 		return nil
 	})(resp.Body)
 	if err != nil {
-		c.Logger.Println(err)
+		c.Logger.Debug(err)
 		return -1, err
 	}
 
@@ -649,7 +673,7 @@ func (c *Checker) HashTagCheck() (int, error) {
 
 func (c *Checker) TweetSearchCheck() (int, error) {
 	//検索できる
-	query := randomWord()
+	query := randomWord(c.Rand)
 
 	resp, err := c.Session.SendSimpleRequest(http.MethodGet, fmt.Sprintf("http://%s/search?q=%s", c.Host, url.QueryEscape(query)), nil)
 	if err != nil {
@@ -661,14 +685,14 @@ func (c *Checker) TweetSearchCheck() (int, error) {
 		var e error
 		doc.Find(".tweet").Each(func(_ int, s *goquery.Selection) {
 			if !strings.Contains(s.Text(), query) {
-				e = errors.New("検索対象でないツイートが表示されています")
+				e = failure.New(failure.ContentMismatch, ".tweet", "search.unmatched")
 				return
 			}
 		})
 		return e
 	})(resp.Body)
 	if err != nil {
-		c.Logger.Println(err)
+		c.Logger.Debug(err)
 		return -1, err
 	}
 
@@ -683,6 +707,8 @@ func (c *Checker) LogoutCheck() (int, error) {
 	}
 	defer resp.Body.Close()
 
+	delete(c.Session.Storage, "login")
+
 	err = rootWithoutLogin(resp.Body)
 	if err != nil {
 		return -1, err
@@ -0,0 +1,32 @@
+//go:build ignore
+// +build ignore
+
+// gen_scenario embeds scenario.yaml, the default scenario, into the checker
+// package. Run go generate after changing it.
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strconv"
+)
+
+func main() {
+	buf, err := ioutil.ReadFile("../scenario.yaml")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	src := fmt.Sprintf(`// Code generated by gen_scenario.go from scenario.yaml. DO NOT EDIT.
+
+package checker
+
+// defaultScenario is the content of scenario.yaml
+const defaultScenario = %s
+`, strconv.Quote(string(buf)))
+
+	if err = ioutil.WriteFile("scenario_default.go", []byte(src), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,103 @@
+package checker
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yahoojapan/yisucon/benchmarker/model"
+)
+
+// Kinds of writes recorded in a Journal
+const (
+	WriteTweet    = "tweet"
+	WriteFollow   = "follow"
+	WriteUnfollow = "unfollow"
+)
+
+// Write is a POST the app acknowledged during the bench
+type Write struct {
+	Kind    string
+	Account *model.Account
+	// Text and Hashtag of a tweet
+	Text    string
+	Hashtag string
+	// Target is the user followed or unfollowed
+	Target string
+	At     time.Time
+}
+
+// Journal records the writes of every worker of a run so that they can be
+// verified after the bench
+type Journal struct {
+	l      *sync.Mutex
+	tweets []Write
+	// follows keeps the latest follow state per account and target
+	follows map[[2]string]Write
+}
+
+func NewJournal() *Journal {
+	return &Journal{
+		l:       new(sync.Mutex),
+		follows: make(map[[2]string]Write),
+	}
+}
+
+func (j *Journal) add(w Write) {
+	if j == nil {
+		return
+	}
+
+	defer j.l.Unlock()
+	j.l.Lock()
+
+	w.At = time.Now()
+
+	if w.Kind == WriteTweet {
+		j.tweets = append(j.tweets, w)
+		return
+	}
+
+	j.follows[[2]string{w.Account.Name, w.Target}] = w
+}
+
+// Len returns the number of writes Sample draws from
+func (j *Journal) Len() int {
+	defer j.l.Unlock()
+	j.l.Lock()
+	return len(j.tweets) + len(j.follows)
+}
+
+// Sample draws up to n writes with rnd. Follow writes are only the final
+// state of each account and target.
+func (j *Journal) Sample(n int, rnd *rand.Rand) []Write {
+	defer j.l.Unlock()
+	j.l.Lock()
+
+	all := make([]Write, 0, len(j.tweets)+len(j.follows))
+	all = append(all, j.tweets...)
+	for _, w := range j.follows {
+		all = append(all, w)
+	}
+
+	// map order is random, sort the follows to keep the draw replayable
+	follows := all[len(j.tweets):]
+	sort.Slice(follows, func(a, b int) bool {
+		if follows[a].Account.Name != follows[b].Account.Name {
+			return follows[a].Account.Name < follows[b].Account.Name
+		}
+		return follows[a].Target < follows[b].Target
+	})
+
+	if n > len(all) {
+		n = len(all)
+	}
+
+	sample := make([]Write, 0, n)
+	for _, i := range rnd.Perm(len(all))[:n] {
+		sample = append(sample, all[i])
+	}
+
+	return sample
+}
@@ -2,30 +2,255 @@ package checker
 
 import (
 	"container/list"
+	"fmt"
+	"io/ioutil"
 	"net/http"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/yahoojapan/yisucon/benchmarker/config"
 )
 
 type Scenario struct {
 	Actions *list.List
+	mix     *mix
 }
 
 const (
-	fakeLoginCount = 2
-	pagingCount    = 5
+	// ModeSequence runs the steps in order (default)
+	ModeSequence = "sequence"
+	// ModeWeighted draws each next step from the step weights
+	ModeWeighted = "weighted"
+
+	defaultMixLength = 100
 )
 
+// Step is one entry of a scenario definition
+type Step struct {
+	// Action is the name of a registered Checker method
+	Action string `yaml:"action"`
+	// Repeat is how many times the action runs in a row (default 1)
+	Repeat int `yaml:"repeat"`
+	// Weight is the relative frequency of the action (default 1)
+	Weight float64 `yaml:"weight"`
+	// Assets wraps each run with the favicon, JS and CSS fetches. It is
+	// ignored with browser_assets, where each page fetches its own assets.
+	Assets bool `yaml:"assets"`
+}
+
+// Definition describes the action sequence of each worker
+type Definition struct {
+	// Mode is either sequence or weighted
+	Mode string `yaml:"mode"`
+	// Length is the number of draws per worker run in weighted mode
+	Length int    `yaml:"length"`
+	Steps  []Step `yaml:"steps"`
+}
+
+type entry struct {
+	method string
+	check  func(*Checker) (int, error)
+	// requires tells whether the session state allows the action in weighted mode
+	requires func(storage map[string]interface{}) bool
+}
+
+var registry = map[string]entry{
+	"InitialCheck":        {http.MethodGet, (*Checker).InitialCheck, nil},
+	"FaviconCheck":        {http.MethodGet, (*Checker).FaviconCheck, nil},
+	"JSCheck":             {http.MethodGet, (*Checker).JSCheck, nil},
+	"CSSCheck":            {http.MethodGet, (*Checker).CSSCheck, nil},
+	"PageLoadCheck":       {http.MethodGet, (*Checker).PageLoadCheck, nil},
+	"MyPageCheck":         {http.MethodGet, (*Checker).MyPageCheck, nil},
+	"LoginPageCheck":      {http.MethodGet, (*Checker).LoginPageCheck, isGuest},
+	"FakeLoginCheck":      {http.MethodPost, (*Checker).FakeLoginCheck, isGuest},
+	"LoginCheck":          {http.MethodPost, (*Checker).LoginCheck, isGuest},
+	"PagingCheck":         {http.MethodGet, (*Checker).PagingCheck, hasUntil},
+	"SelfPageCheck":       {http.MethodGet, (*Checker).SelfPageCheck, isLoggedIn},
+	"UnfollowButtonCheck": {http.MethodGet, (*Checker).UnfollowButtonCheck, isFollowing},
+	"UnfollowCheck":       {http.MethodPost, (*Checker).UnfollowCheck, isFollowing},
+	"RemoveFromTopCheck":  {http.MethodGet, (*Checker).RemoveFromTopCheck, isUnfollowed},
+	"FollowButtonCheck":   {http.MethodGet, (*Checker).FollowButtonCheck, isUnfollowed},
+	"FollowCheck":         {http.MethodPost, (*Checker).FollowCheck, isUnfollowed},
+	"FollowerTweetCheck":  {http.MethodGet, (*Checker).FollowerTweetCheck, isFollowing},
+	"HashTagTweetCheck":   {http.MethodPost, (*Checker).HashTagTweetCheck, isLoggedIn},
+	"TweetCheck":          {http.MethodGet, (*Checker).TweetCheck, hasTweet},
+	"HashTagCheck":        {http.MethodGet, (*Checker).HashTagCheck, hasTweet},
+	"TweetSearchCheck":    {http.MethodGet, (*Checker).TweetSearchCheck, nil},
+	"LogoutCheck":         {http.MethodPost, (*Checker).LogoutCheck, isLoggedIn},
+}
+
+// aliases maps former action names to their registered name so that older
+// scenario files keep working
+var aliases = map[string]string{
+	"InitialiCheck": "InitialCheck",
+}
+
+//go:generate go run gen_scenario.go
+
+// defaultDefinition is scenario.yaml, embedded by go generate
+var defaultDefinition = mustParse(defaultScenario)
+
+var definition = defaultDefinition
+
+// LoadScenario replaces the default scenario with the definition in path
+// (YAML or JSON). An empty path keeps the default.
+func LoadScenario(path string) error {
+	if len(path) == 0 {
+		return nil
+	}
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	def, err := parse(buf)
+	if err != nil {
+		return fmt.Errorf("scenario %s : %v", path, err)
+	}
+
+	definition = def
+
+	return nil
+}
+
+// parse reads and validates a scenario definition
+func parse(buf []byte) (*Definition, error) {
+	def := new(Definition)
+
+	if err := yaml.UnmarshalStrict(buf, def); err != nil {
+		return nil, err
+	}
+
+	if err := def.Validate(); err != nil {
+		return nil, err
+	}
+
+	return def, nil
+}
+
+func mustParse(src string) *Definition {
+	def, err := parse([]byte(src))
+	if err != nil {
+		panic(fmt.Sprintf("default scenario : %v", err))
+	}
+	return def
+}
+
+// Validate checks that every step names a registered action, renames the
+// former names of actions, and fills in the default repeat and weight
+func (d *Definition) Validate() error {
+	if len(d.Steps) == 0 {
+		return fmt.Errorf("no steps defined")
+	}
+
+	switch d.Mode {
+	case "":
+		d.Mode = ModeSequence
+	case ModeSequence, ModeWeighted:
+	default:
+		return fmt.Errorf("unknown mode %q", d.Mode)
+	}
+
+	if d.Length < 0 {
+		return fmt.Errorf("negative length %d", d.Length)
+	}
+
+	if d.Length == 0 {
+		d.Length = defaultMixLength
+	}
+
+	for i := range d.Steps {
+		st := &d.Steps[i]
+		if name, ok := aliases[st.Action]; ok {
+			st.Action = name
+		}
+		if _, ok := registry[st.Action]; !ok {
+			return fmt.Errorf("step %d : unknown action %q", i+1, st.Action)
+		}
+		if st.Repeat < 0 {
+			return fmt.Errorf("step %d : negative repeat %d", i+1, st.Repeat)
+		}
+		if st.Weight < 0 {
+			return fmt.Errorf("step %d : negative weight %v", i+1, st.Weight)
+		}
+		if st.Repeat == 0 {
+			st.Repeat = 1
+		}
+		if st.Weight == 0 {
+			st.Weight = 1
+		}
+	}
+
+	return nil
+}
+
+func isLoggedIn(storage map[string]interface{}) bool {
+	_, ok := storage["login"]
+	return ok
+}
+
+func isGuest(storage map[string]interface{}) bool {
+	return !isLoggedIn(storage)
+}
+
+func isFollowing(storage map[string]interface{}) bool {
+	following, ok := storage["following"].(bool)
+	return isLoggedIn(storage) && ok && following
+}
+
+func isUnfollowed(storage map[string]interface{}) bool {
+	following, ok := storage["following"].(bool)
+	return isLoggedIn(storage) && ok && !following
+}
+
+func hasUntil(storage map[string]interface{}) bool {
+	_, ok := storage["until"]
+	return isLoggedIn(storage) && ok
+}
+
+func hasTweet(storage map[string]interface{}) bool {
+	_, ok := storage["tweet"]
+	return isLoggedIn(storage) && ok
+}
+
+func newRegisteredAction(c *Checker, name string) *Action {
+	e := registry[name]
+	return NewAction(e.method, name, func() (int, error) {
+		return e.check(c)
+	})
+}
+
+func pushWithAssets(queue *list.List, c *Checker, action *Action) {
+	queue.PushBack(newRegisteredAction(c, "FaviconCheck"))
+	queue.PushBack(action)
+	queue.PushBack(newRegisteredAction(c, "JSCheck"))
+	queue.PushBack(newRegisteredAction(c, "CSSCheck"))
+}
+
 func NewInitScenario(c *Checker) *Scenario {
 
 	queue := list.New()
 
 	queue.Init()
 
-	queue.PushBack(NewAction(http.MethodGet, "InitialiCheck", c.InitialCheck))
-	queue.PushBack(NewAction(http.MethodGet, "FaviconCheck", c.FaviconCheck))
-	queue.PushBack(NewAction(http.MethodGet, "JSCheck", c.JSCheck))
-	queue.PushBack(NewAction(http.MethodGet, "CSSCheck", c.CSSCheck))
+	queue.PushBack(newRegisteredAction(c, "InitialCheck"))
+	queue.PushBack(newRegisteredAction(c, "FaviconCheck"))
+	queue.PushBack(newRegisteredAction(c, "JSCheck"))
+	queue.PushBack(newRegisteredAction(c, "CSSCheck"))
 
-	return &Scenario{queue}
+	return &Scenario{Actions: queue}
+}
+
+func pushStep(queue *list.List, c *Checker, st Step) {
+	action := newRegisteredAction(c, st.Action)
+	for i := 0; i < st.Repeat; i++ {
+		if st.Assets && !config.BrowserAssets {
+			pushWithAssets(queue, c, action)
+		} else {
+			queue.PushBack(action)
+		}
+	}
 }
 
 func NewDefaultScenario(c *Checker) *Scenario {
@@ -34,61 +259,79 @@ func NewDefaultScenario(c *Checker) *Scenario {
 
 	queue.Init()
 
-	actions := Actions{
-		NewAction(http.MethodGet, "PageLoadCheck", c.PageLoadCheck),
-		NewAction(http.MethodGet, "MyPageCheck", c.MyPageCheck),
-		NewAction(http.MethodGet, "LoginPageCheck", c.LoginPageCheck),
-		NewAction(http.MethodPost, "FakeLoginCheck", c.FakeLoginCheck),
-		NewAction(http.MethodPost, "LoginCheck", c.LoginCheck),
-		NewAction(http.MethodGet, "PagingCheck", c.PagingCheck),
-		NewAction(http.MethodGet, "SelfPageCheck", c.SelfPageCheck),
-		NewAction(http.MethodGet, "UnfollowButtonCheck", c.UnfollowButtonCheck),
-		NewAction(http.MethodPost, "UnfollowCheck", c.UnfollowCheck),
-		NewAction(http.MethodGet, "RemoveFromTopCheck", c.RemoveFromTopCheck),
-		NewAction(http.MethodGet, "FollowButtonCheck", c.FollowButtonCheck),
-		NewAction(http.MethodPost, "FollowCheck", c.FollowCheck),
-		NewAction(http.MethodGet, "FollowerTweetCheck", c.FollowerTweetCheck),
-		NewAction(http.MethodGet, "UnfollowButtonCheck", c.UnfollowButtonCheck),
-		NewAction(http.MethodPost, "HashTagTweetCheck", c.HashTagTweetCheck),
-		NewAction(http.MethodGet, "TweetCheck", c.TweetCheck),
-		NewAction(http.MethodGet, "HashTagCheck", c.HashTagCheck),
-		NewAction(http.MethodGet, "TweetSearchCheck", c.TweetSearchCheck),
-		NewAction(http.MethodPost, "LogoutCheck", c.LogoutCheck),
-	}
-
-	for _, action := range actions {
-		switch action.Name {
-		case "FakeLoginCheck":
-			for i := 0; i < fakeLoginCount; i++ {
-				queue.PushBack(NewAction(http.MethodGet, "FaviconCheck", c.FaviconCheck))
-				queue.PushBack(action)
-				queue.PushBack(NewAction(http.MethodGet, "JSCheck", c.JSCheck))
-				queue.PushBack(NewAction(http.MethodGet, "CSSCheck", c.CSSCheck))
-			}
-		case "PagingCheck":
-			for i := 0; i < pagingCount; i++ {
-				queue.PushBack(action)
-			}
-		default:
-			queue.PushBack(NewAction(http.MethodGet, "FaviconCheck", c.FaviconCheck))
-			queue.PushBack(action)
-			queue.PushBack(NewAction(http.MethodGet, "JSCheck", c.JSCheck))
-			queue.PushBack(NewAction(http.MethodGet, "CSSCheck", c.CSSCheck))
+	if definition.Mode == ModeWeighted {
+		return &Scenario{
+			Actions: queue,
+			mix: &mix{
+				c:         c,
+				steps:     definition.Steps,
+				remaining: definition.Length,
+			},
 		}
 	}
 
-	return &Scenario{queue}
+	for _, st := range definition.Steps {
+		pushStep(queue, c, st)
+	}
+
+	return &Scenario{Actions: queue}
+}
+
+// mix draws steps by weight among those allowed by the session state
+type mix struct {
+	c         *Checker
+	steps     []Step
+	remaining int
+}
+
+func (m *mix) draw() Step {
+	m.remaining--
+
+	storage := m.c.Session.Storage
+
+	eligible := make([]Step, 0, len(m.steps))
+	total := 0.0
+
+	for _, st := range m.steps {
+		if req := registry[st.Action].requires; req == nil || req(storage) {
+			eligible = append(eligible, st)
+			total += st.Weight
+		}
+	}
+
+	if len(eligible) == 0 {
+		// no step fits the current state, toggle the login state instead
+		if isGuest(storage) {
+			return Step{Action: "LoginCheck", Repeat: 1}
+		}
+		return Step{Action: "LogoutCheck", Repeat: 1}
+	}
+
+	r := m.c.Rand.Float64() * total
+
+	for _, st := range eligible {
+		if r < st.Weight {
+			return st
+		}
+		r -= st.Weight
+	}
+
+	return eligible[len(eligible)-1]
 }
 
 func (s *Scenario) Close() {
 	s.Actions.Init()
 	s.Actions = nil
+	s.mix = nil
 }
 
 func (s *Scenario) Pop() *Action {
+	if s.Actions.Len() == 0 && s.mix != nil {
+		pushStep(s.Actions, s.mix.c, s.mix.draw())
+	}
 	return s.Actions.Remove(s.Actions.Front()).(*Action)
 }
 
 func (s *Scenario) IsEmpty() bool {
-	return s.Actions.Len() == 0
+	return s.Actions.Len() == 0 && (s.mix == nil || s.mix.remaining <= 0)
 }
@@ -0,0 +1,57 @@
+package checker
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestDefaultScenarioGenerated(t *testing.T) {
+	buf, err := ioutil.ReadFile("../scenario.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(buf) != defaultScenario {
+		t.Error("scenario.yaml changed, run go generate ./checker")
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		actions []string
+		wantErr bool
+	}{
+		{"sequence", "steps: [{action: LoginCheck}, {action: LogoutCheck}]", []string{"LoginCheck", "LogoutCheck"}, false},
+		{"alias", "steps: [{action: InitialiCheck}]", []string{"InitialCheck"}, false},
+		{"unknown action", "steps: [{action: NoSuchCheck}]", nil, true},
+		{"unknown key", "steps: [{action: LoginCheck, wait: 1}]", nil, true},
+		{"unknown mode", "mode: random\nsteps: [{action: LoginCheck}]", nil, true},
+		{"no steps", "mode: sequence", nil, true},
+		{"negative repeat", "steps: [{action: LoginCheck, repeat: -1}]", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def, err := parse([]byte(tt.src))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(def.Steps) != len(tt.actions) {
+				t.Fatalf("got %d steps, want %d", len(def.Steps), len(tt.actions))
+			}
+			for i, st := range def.Steps {
+				if st.Action != tt.actions[i] {
+					t.Errorf("step %d : got %s, want %s", i+1, st.Action, tt.actions[i])
+				}
+				if st.Repeat != 1 || st.Weight != 1 {
+					t.Errorf("step %d : got repeat %d weight %v, want the defaults", i+1, st.Repeat, st.Weight)
+				}
+			}
+		})
+	}
+}
@@ -1,32 +1,36 @@
 package checker
 
 import (
-	"errors"
 	"fmt"
 	"io"
 	"math/rand"
-	"time"
+	"net/url"
+	"path"
+	"strings"
 
 	"github.com/PuerkitoBio/goquery"
+
+	"github.com/yahoojapan/yisucon/benchmarker/failure"
+	"github.com/yahoojapan/yisucon/benchmarker/util"
 )
 
 var (
 	rootWithoutLogin = checkHTML(func(doc *goquery.Document) error {
 		login := doc.Find(".login")
 		if login.Length() == 0 {
-			return errors.New("未ログイン時にログインフォームが見つかりません")
+			return failure.New(failure.MissingElement, ".login", "guest.no_login_form")
 		}
 		logout := doc.Find(".logout")
 		if logout.Length() != 0 {
-			return errors.New("未ログイン時にログアウトボタンが存在します")
+			return failure.New(failure.ContentMismatch, ".logout", "guest.logout_button")
 		}
 		name := doc.Find(".name")
 		if name.Text() != "こんにちは ゲストさん" {
-			return errors.New("非ログイン時にユーザー名が見つかりません")
+			return failure.New(failure.ContentMismatch, ".name", "guest.no_name")
 		}
 		post := doc.Find(".post")
 		if post.Length() != 0 {
-			return errors.New("未ログイン時にツイートフォームが存在します")
+			return failure.New(failure.ContentMismatch, ".post", "guest.tweet_form")
 		}
 
 		return nil
@@ -38,32 +42,72 @@ const (
 	cssMD5 = "9dca706b1509accdaa68f07155a3c45f"
 )
 
+// Static asset errors, which disqualify a run
+var (
+	ErrJSMismatch  = failure.Asset("/js/script.js", "asset.js")
+	ErrCSSMismatch = failure.Asset("/css/style.css", "asset.css")
+	ErrJSNoBody    = failure.Asset("/js/script.js", "asset.js_empty")
+	ErrCSSNoBody   = failure.Asset("/css/style.css", "asset.css_empty")
+)
+
+// checkAsset compares the assets of a page whose content is known
+func checkAsset(u *url.URL, body []byte) error {
+	switch u.Path {
+	case "/js/script.js":
+		if util.GetMD5(body) != jsMD5 {
+			return ErrJSMismatch
+		}
+	case "/css/style.css":
+		if util.GetMD5(body) != cssMD5 {
+			return ErrCSSMismatch
+		}
+	}
+	return nil
+}
+
 func checkHTML(f func(*goquery.Document) error) func(io.Reader) error {
 	return func(r io.Reader) error {
 		doc, err := goquery.NewDocumentFromReader(r)
 		if err != nil {
-			return err
+			return failure.Wrap(failure.ContentMismatch, err)
 		}
 		return f(doc)
 	}
 }
 
-func randomIntString() string {
-	rand.Seed(time.Now().UnixNano())
-	return fmt.Sprint(rand.Intn(10000))
+// pathTemplate maps isuwitter URLs to their route, e.g. /hashtag/:tag
+func pathTemplate(u *url.URL) string {
+	switch p := u.Path; {
+	case p == "":
+		return "/"
+	case p == "/", p == "/initialize", p == "/login", p == "/logout",
+		p == "/follow", p == "/unfollow", p == "/search",
+		p == "/favicon.ico", p == "/js/script.js", p == "/css/style.css":
+		return p
+	case strings.HasPrefix(p, "/hashtag/"):
+		return "/hashtag/:tag"
+	case path.Ext(p) != "":
+		// assets found in the pages, user names have no dot
+		return p
+	default:
+		return "/:user"
+	}
+}
+
+func randomIntString(rnd *rand.Rand) string {
+	return fmt.Sprint(rnd.Intn(10000))
 }
 
-func randomWord() string {
-	rand.Seed(time.Now().UnixNano())
+func randomWord(rnd *rand.Rand) string {
 	words := []string{"スポーツ", "sports", "募集", "ダイエット", "travel", "旅行", "海外", "foods", "食事", "美味しい", "おすすめ"}
-	return words[rand.Intn(len(words))]
+	return words[rnd.Intn(len(words))]
 }
 
-func randomPass() string {
+func randomPass(rnd *rand.Rand) string {
 	atoz := []rune("abcdefghijklmnopqrstuvwxyz")
-	buf := make([]rune, rand.Intn(4)+4)
+	buf := make([]rune, rnd.Intn(4)+4)
 	for i := range buf {
-		buf[i] = atoz[rand.Intn(len(atoz))]
+		buf[i] = atoz[rnd.Intn(len(atoz))]
 	}
 	return string(buf)
 }
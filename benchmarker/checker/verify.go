@@ -0,0 +1,111 @@
+package checker
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/yahoojapan/yisucon/benchmarker/failure"
+)
+
+// Errors of the post-bench verification
+var (
+	ErrLostTweet    = failure.New(failure.MissingElement, ".tweet", "verify.tweet")
+	ErrLostFollow   = failure.New(failure.MissingElement, "#user-unfollow-button", "verify.follow")
+	ErrLostUnfollow = failure.New(failure.MissingElement, "#user-follow-button", "verify.unfollow")
+)
+
+// verifyPages bounds the pages of a user crawled to find a tweet
+const verifyPages = 20
+
+// Verify confirms that the app kept w. c must be a fresh checker of w.Account.
+func (c *Checker) Verify(w Write) error {
+	var err error
+
+	if w.Kind == WriteTweet {
+		err = c.verifyTweet(w)
+	} else {
+		err = c.verifyFollow(w)
+	}
+
+	return c.withRequest(err)
+}
+
+func (c *Checker) verifyTweet(w Write) error {
+	uri := fmt.Sprintf("http://%s/%s", c.Host, w.Account.Name)
+
+	for page := 0; page < verifyPages; page++ {
+		resp, err := c.Session.SendSimpleRequest(http.MethodGet, uri, nil)
+		if err != nil {
+			return err
+		}
+
+		var found bool
+		var count int
+		var last string
+
+		err = checkHTML(func(doc *goquery.Document) error {
+			tweets := doc.Find(".tweet")
+			count = tweets.Length()
+			tweets.EachWithBreak(func(_ int, s *goquery.Selection) bool {
+				if strings.Contains(s.Text(), w.Text) && s.Find(".hashtag").Text() == "#"+w.Hashtag {
+					found = true
+					return false
+				}
+				return true
+			})
+			last, _ = tweets.Last().Attr("data-time")
+			return nil
+		})(resp.Body)
+		resp.Body.Close()
+
+		if err != nil {
+			return err
+		}
+
+		if found {
+			return nil
+		}
+
+		if count < 50 || len(last) == 0 {
+			return ErrLostTweet
+		}
+
+		older, err := time.Parse("2006-01-02 15:04:05", last)
+		if err != nil {
+			return failure.New(failure.ContentMismatch, ".tweet[data-time]", "tweet.bad_time")
+		}
+
+		// tweets of the boundary second come again rather than being skipped
+		until := older.Add(time.Second).Format("2006-01-02 15:04:05")
+		uri = fmt.Sprintf("http://%s/%s?append=1&until=%s", c.Host, w.Account.Name, url.QueryEscape(until))
+	}
+
+	return ErrLostTweet
+}
+
+func (c *Checker) verifyFollow(w Write) error {
+	if _, err := c.LoginCheck(); err != nil {
+		return err
+	}
+
+	resp, err := c.Session.SendSimpleRequest(http.MethodGet, fmt.Sprintf("http://%s/%s", c.Host, w.Target), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkHTML(func(doc *goquery.Document) error {
+		if w.Kind == WriteFollow && doc.Find(`#user-unfollow-button`).Text() != "アンフォロー" {
+			return ErrLostFollow
+		}
+		if w.Kind == WriteUnfollow && doc.Find(`#user-follow-button`).Text() != "フォロー" {
+			return ErrLostUnfollow
+		}
+		return nil
+	})(resp.Body)
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"time"
 )
@@ -8,12 +9,104 @@ import (
 var PortalHost = os.Getenv("YJ_ISUCON_PORTAL_HOST")
 
 const (
+	BenchMarkerUA = "YISUCON"
+
+	// LoadModeFixed keeps MaxWorkerCount workers for the whole bench
+	LoadModeFixed = "fixed"
+	// LoadModeRamp adds RampStep workers every RampInterval after RampWarmup
+	// while the error rate stays under RampErrorRate
+	LoadModeRamp = "ramp"
+
+	// JobSourceMySQL polls the team_queue table of the contest DB
+	JobSourceMySQL = "mysql"
+	// JobSourceHTTP pulls jobs from the portal API
+	JobSourceHTTP = "http"
+	// JobSourceFile reads jobs from JobFile and exits when it is exhausted
+	JobSourceFile = "file"
+
+	// ScoringModeCount scores each success by the rule table alone
+	ScoringModeCount = "count"
+	// ScoringModeLatency also multiplies the points of successes by the
+	// LatencyTiers their latency falls in
+	ScoringModeLatency = "latency"
+
+	// LocaleJA and LocaleEN are the languages of the failure messages
+	LocaleJA = "ja"
+	LocaleEN = "en"
+)
+
+// Tunable parameters. Defaults are overridden by Load.
+var (
 	MaxWorkerCount     = 5
 	MaxCheckers        = 30
 	InitializeTimeout  = time.Second * 10
 	BenchTimeLimit     = time.Minute
 	QueueCheckDuration = time.Second * 2
 	RequestTimeout     = time.Second * 30
-	BenchMarkerUA      = "YISUCON"
 	LogFilePath        = "/tmp/isucon/benchmarker.log"
+	LogFormat          = "text"
+	LogLevel           = "info"
+	LogMaxSize         = 100
+	LogMaxBackups      = 3
+	ReportPath         = ""
+	TimelinePath       = ""
+	ScenarioPath       = ""
+	LoadMode           = LoadModeFixed
+	RampWarmup         = time.Second * 10
+	RampInterval       = time.Second * 5
+	RampStep           = 5
+	RampMaxWorkers     = 50
+	RampErrorRate      = 0.05
+	RampWorkerBonus    = 100
+	Seed               int64
+	MetricsAddr        = ""
+	MaxParallelBenches = 1
+	JobSource          = JobSourceMySQL
+	JobFile            = "-"
+	JobResultPath      = "-"
+	WorkerID           = workerID()
+	LeaseDuration      = time.Minute * 2
+	HeartbeatInterval  = time.Second * 20
+	MaxAttempts        = 3
+	TranscriptSize     = 50
+
+	FailOnAssetMismatch    = true
+	FailOnPostTimeout      = true
+	FailConsistencyRate    = 0.1
+	FailConsistencyMin     = 20
+	FailConsistencyActions = "MyPageCheck,TweetCheck"
+
+	VerifySample   = 20
+	VerifyTimeout  = time.Second * 30
+	VerifyPenalty  = 100
+	VerifyFailRate = 0.2
+
+	RestartAgentPort = 0
+	RestartToken     = ""
+	RestartTimeout   = time.Minute * 3
+
+	CacheShared = false
+
+	BrowserAssets = false
+	AssetParallel = 6
+
+	TLSCABundle   = ""
+	TLSSkipVerify = false
+	HTTP2         = true
+
+	ScoringRules = ""
+	ScoringMode  = ScoringModeCount
+	LatencyTiers = "100ms:4,1s:2,3s:1"
+
+	// Locale of the messages of a run unless its team has one
+	Locale = LocaleJA
 )
+
+// workerID names this benchmarker process in the queue leases
+func workerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
@@ -0,0 +1,504 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Config is the effective set of tunable benchmark parameters
+type Config struct {
+	MaxWorkerCount     int           `yaml:"max_worker_count" toml:"max_worker_count"`
+	MaxCheckers        int           `yaml:"max_checkers" toml:"max_checkers"`
+	InitializeTimeout  time.Duration `yaml:"initialize_timeout" toml:"initialize_timeout"`
+	BenchTimeLimit     time.Duration `yaml:"bench_time_limit" toml:"bench_time_limit"`
+	QueueCheckDuration time.Duration `yaml:"queue_check_duration" toml:"queue_check_duration"`
+	RequestTimeout     time.Duration `yaml:"request_timeout" toml:"request_timeout"`
+	LogFilePath        string        `yaml:"log_file_path" toml:"log_file_path"`
+	LogFormat          string        `yaml:"log_format" toml:"log_format"`
+	LogLevel           string        `yaml:"log_level" toml:"log_level"`
+	LogMaxSize         int           `yaml:"log_max_size" toml:"log_max_size"`
+	LogMaxBackups      int           `yaml:"log_max_backups" toml:"log_max_backups"`
+	ReportPath         string        `yaml:"report_path" toml:"report_path"`
+	TimelinePath       string        `yaml:"timeline_path" toml:"timeline_path"`
+	ScenarioPath       string        `yaml:"scenario_path" toml:"scenario_path"`
+	LoadMode           string        `yaml:"load_mode" toml:"load_mode"`
+	RampWarmup         time.Duration `yaml:"ramp_warmup" toml:"ramp_warmup"`
+	RampInterval       time.Duration `yaml:"ramp_interval" toml:"ramp_interval"`
+	RampStep           int           `yaml:"ramp_step" toml:"ramp_step"`
+	RampMaxWorkers     int           `yaml:"ramp_max_workers" toml:"ramp_max_workers"`
+	RampErrorRate      float64       `yaml:"ramp_error_rate" toml:"ramp_error_rate"`
+	RampWorkerBonus    int           `yaml:"ramp_worker_bonus" toml:"ramp_worker_bonus"`
+	Seed               int64         `yaml:"seed" toml:"seed"`
+	MetricsAddr        string        `yaml:"metrics_addr" toml:"metrics_addr"`
+	MaxParallelBenches int           `yaml:"max_parallel_benches" toml:"max_parallel_benches"`
+	JobSource          string        `yaml:"job_source" toml:"job_source"`
+	JobFile            string        `yaml:"job_file" toml:"job_file"`
+	JobResultPath      string        `yaml:"job_result_path" toml:"job_result_path"`
+	WorkerID           string        `yaml:"worker_id" toml:"worker_id"`
+	LeaseDuration      time.Duration `yaml:"lease_duration" toml:"lease_duration"`
+	HeartbeatInterval  time.Duration `yaml:"heartbeat_interval" toml:"heartbeat_interval"`
+	MaxAttempts        int           `yaml:"max_attempts" toml:"max_attempts"`
+	TranscriptSize     int           `yaml:"transcript_size" toml:"transcript_size"`
+
+	FailOnAssetMismatch    bool    `yaml:"fail_on_asset_mismatch" toml:"fail_on_asset_mismatch"`
+	FailOnPostTimeout      bool    `yaml:"fail_on_post_timeout" toml:"fail_on_post_timeout"`
+	FailConsistencyRate    float64 `yaml:"fail_consistency_rate" toml:"fail_consistency_rate"`
+	FailConsistencyMin     int     `yaml:"fail_consistency_min" toml:"fail_consistency_min"`
+	FailConsistencyActions string  `yaml:"fail_consistency_actions" toml:"fail_consistency_actions"`
+
+	VerifySample   int           `yaml:"verify_sample" toml:"verify_sample"`
+	VerifyTimeout  time.Duration `yaml:"verify_timeout" toml:"verify_timeout"`
+	VerifyPenalty  int           `yaml:"verify_penalty" toml:"verify_penalty"`
+	VerifyFailRate float64       `yaml:"verify_fail_rate" toml:"verify_fail_rate"`
+
+	RestartAgentPort int           `yaml:"restart_agent_port" toml:"restart_agent_port"`
+	RestartToken     string        `yaml:"restart_token" toml:"restart_token"`
+	RestartTimeout   time.Duration `yaml:"restart_timeout" toml:"restart_timeout"`
+
+	CacheShared bool `yaml:"cache_shared" toml:"cache_shared"`
+
+	BrowserAssets bool `yaml:"browser_assets" toml:"browser_assets"`
+	AssetParallel int  `yaml:"asset_parallel" toml:"asset_parallel"`
+
+	TLSCABundle   string `yaml:"tls_ca_bundle" toml:"tls_ca_bundle"`
+	TLSSkipVerify bool   `yaml:"tls_skip_verify" toml:"tls_skip_verify"`
+	HTTP2         bool   `yaml:"http2" toml:"http2"`
+
+	ScoringRules string `yaml:"scoring_rules" toml:"scoring_rules"`
+	ScoringMode  string `yaml:"scoring_mode" toml:"scoring_mode"`
+	LatencyTiers string `yaml:"latency_tiers" toml:"latency_tiers"`
+
+	Locale string `yaml:"locale" toml:"locale"`
+}
+
+type param struct {
+	key   string
+	flag  string
+	env   string
+	usage string
+	ptr   interface{}
+}
+
+// ConfigFileEnv names the environment variable holding the config file path
+const ConfigFileEnv = "YJ_ISUCON_BENCH_CONFIG"
+
+func params(c *Config) []param {
+	return []param{
+		{"max_worker_count", "workers", "YJ_ISUCON_BENCH_WORKERS", "number of concurrent workers", &c.MaxWorkerCount},
+		{"max_checkers", "checkers", "YJ_ISUCON_BENCH_CHECKERS", "result buffer size per worker", &c.MaxCheckers},
+		{"initialize_timeout", "initialize-timeout", "YJ_ISUCON_BENCH_INITIALIZE_TIMEOUT", "timeout of GET /initialize", &c.InitializeTimeout},
+		{"bench_time_limit", "bench-time", "YJ_ISUCON_BENCH_TIME_LIMIT", "length of the benchmark", &c.BenchTimeLimit},
+		{"queue_check_duration", "queue-check", "YJ_ISUCON_BENCH_QUEUE_CHECK", "queue polling interval", &c.QueueCheckDuration},
+		{"request_timeout", "request-timeout", "YJ_ISUCON_BENCH_REQUEST_TIMEOUT", "timeout of each request", &c.RequestTimeout},
+		{"log_file_path", "log", "YJ_ISUCON_BENCH_LOG", "log file path", &c.LogFilePath},
+		{"log_format", "log-format", "YJ_ISUCON_BENCH_LOG_FORMAT", "log format (text or json)", &c.LogFormat},
+		{"log_level", "log-level", "YJ_ISUCON_BENCH_LOG_LEVEL", "lowest logged level (debug, info, warn or error)", &c.LogLevel},
+		{"log_max_size", "log-max-size", "YJ_ISUCON_BENCH_LOG_MAX_SIZE", "log file size in MB that triggers a rotation (0 disables)", &c.LogMaxSize},
+		{"log_max_backups", "log-max-backups", "YJ_ISUCON_BENCH_LOG_MAX_BACKUPS", "rotated log files to keep", &c.LogMaxBackups},
+		{"report_path", "report", "YJ_ISUCON_BENCH_REPORT", "JSON report output path (- for stdout)", &c.ReportPath},
+		{"timeline_path", "timeline", "YJ_ISUCON_BENCH_TIMELINE", "per-second timeline CSV output path (- for stdout)", &c.TimelinePath},
+		{"scenario_path", "scenario", "YJ_ISUCON_BENCH_SCENARIO", "scenario definition file (YAML or JSON)", &c.ScenarioPath},
+		{"load_mode", "load-mode", "YJ_ISUCON_BENCH_LOAD_MODE", "load mode (fixed or ramp)", &c.LoadMode},
+		{"ramp_warmup", "ramp-warmup", "YJ_ISUCON_BENCH_RAMP_WARMUP", "warm-up before the first ramp step", &c.RampWarmup},
+		{"ramp_interval", "ramp-interval", "YJ_ISUCON_BENCH_RAMP_INTERVAL", "interval between ramp steps", &c.RampInterval},
+		{"ramp_step", "ramp-step", "YJ_ISUCON_BENCH_RAMP_STEP", "workers added per ramp step", &c.RampStep},
+		{"ramp_max_workers", "ramp-max-workers", "YJ_ISUCON_BENCH_RAMP_MAX_WORKERS", "upper bound of workers in ramp mode", &c.RampMaxWorkers},
+		{"ramp_error_rate", "ramp-error-rate", "YJ_ISUCON_BENCH_RAMP_ERROR_RATE", "error rate that stops the ramp", &c.RampErrorRate},
+		{"ramp_worker_bonus", "ramp-worker-bonus", "YJ_ISUCON_BENCH_RAMP_WORKER_BONUS", "score per sustained worker above the initial level", &c.RampWorkerBonus},
+		{"seed", "seed", "YJ_ISUCON_BENCH_SEED", "random seed to replay a run (0 picks one)", &c.Seed},
+		{"metrics_addr", "metrics", "YJ_ISUCON_BENCH_METRICS", "listen address of the Prometheus /metrics endpoint (empty disables)", &c.MetricsAddr},
+		{"max_parallel_benches", "parallel", "YJ_ISUCON_BENCH_PARALLEL", "number of teams benchmarked concurrently", &c.MaxParallelBenches},
+		{"job_source", "jobs", "YJ_ISUCON_BENCH_JOB_SOURCE", "where jobs come from (mysql, http or file)", &c.JobSource},
+		{"job_file", "job-file", "YJ_ISUCON_BENCH_JOB_FILE", "job list for the file source (- for stdin)", &c.JobFile},
+		{"job_result_path", "job-results", "YJ_ISUCON_BENCH_JOB_RESULTS", "JSON lines result output of the file source (- for stdout)", &c.JobResultPath},
+		{"worker_id", "worker-id", "YJ_ISUCON_BENCH_WORKER_ID", "name of this benchmarker in queue leases", &c.WorkerID},
+		{"lease_duration", "lease", "YJ_ISUCON_BENCH_LEASE", "how long a claimed job stays ours without a heartbeat", &c.LeaseDuration},
+		{"heartbeat_interval", "heartbeat", "YJ_ISUCON_BENCH_HEARTBEAT", "interval of lease renewals while a job runs", &c.HeartbeatInterval},
+		{"max_attempts", "max-attempts", "YJ_ISUCON_BENCH_MAX_ATTEMPTS", "claims of a job before it is aborted", &c.MaxAttempts},
+		{"transcript_size", "transcript", "YJ_ISUCON_BENCH_TRANSCRIPT", "failed requests kept per run for the team", &c.TranscriptSize},
+		{"fail_on_asset_mismatch", "fail-asset", "YJ_ISUCON_BENCH_FAIL_ASSET", "fail the run on a modified JS or CSS file", &c.FailOnAssetMismatch},
+		{"fail_on_post_timeout", "fail-post-timeout", "YJ_ISUCON_BENCH_FAIL_POST_TIMEOUT", "fail the run on a POST slower than request_timeout", &c.FailOnPostTimeout},
+		{"fail_consistency_rate", "fail-consistency-rate", "YJ_ISUCON_BENCH_FAIL_CONSISTENCY_RATE", "content error rate of fail_consistency_actions that fails the run (0 disables)", &c.FailConsistencyRate},
+		{"fail_consistency_min", "fail-consistency-min", "YJ_ISUCON_BENCH_FAIL_CONSISTENCY_MIN", "runs of fail_consistency_actions before the rate is judged", &c.FailConsistencyMin},
+		{"fail_consistency_actions", "fail-consistency-actions", "YJ_ISUCON_BENCH_FAIL_CONSISTENCY_ACTIONS", "comma separated actions judged by fail_consistency_rate", &c.FailConsistencyActions},
+		{"verify_sample", "verify-sample", "YJ_ISUCON_BENCH_VERIFY_SAMPLE", "writes checked again after the bench (0 disables)", &c.VerifySample},
+		{"verify_timeout", "verify-timeout", "YJ_ISUCON_BENCH_VERIFY_TIMEOUT", "time limit of the post-bench verification", &c.VerifyTimeout},
+		{"verify_penalty", "verify-penalty", "YJ_ISUCON_BENCH_VERIFY_PENALTY", "score taken per lost write", &c.VerifyPenalty},
+		{"verify_fail_rate", "verify-fail-rate", "YJ_ISUCON_BENCH_VERIFY_FAIL_RATE", "lost write rate of the sample that fails the run", &c.VerifyFailRate},
+		{"restart_agent_port", "restart-agent-port", "YJ_ISUCON_BENCH_RESTART_AGENT_PORT", "port of the restart agent on the target host, verifies writes again after a restart (0 disables)", &c.RestartAgentPort},
+		{"restart_token", "restart-token", "YJ_ISUCON_BENCH_RESTART_TOKEN", "shared secret sent to the restart agent", &c.RestartToken},
+		{"restart_timeout", "restart-timeout", "YJ_ISUCON_BENCH_RESTART_TIMEOUT", "time limit of the restart until the app answers again", &c.RestartTimeout},
+		{"cache_shared", "cache-shared", "YJ_ISUCON_BENCH_CACHE_SHARED", "cache responses like a shared proxy (skip private, prefer s-maxage)", &c.CacheShared},
+		{"browser_assets", "browser-assets", "YJ_ISUCON_BENCH_BROWSER_ASSETS", "fetch the assets referenced by each HTML page instead of the scenario's fixed asset checks", &c.BrowserAssets},
+		{"asset_parallel", "asset-parallel", "YJ_ISUCON_BENCH_ASSET_PARALLEL", "assets of a page fetched at a time", &c.AssetParallel},
+		{"tls_ca_bundle", "tls-ca-bundle", "YJ_ISUCON_BENCH_TLS_CA_BUNDLE", "PEM file of the CAs trusted for https:// targets (system roots when empty)", &c.TLSCABundle},
+		{"tls_skip_verify", "tls-skip-verify", "YJ_ISUCON_BENCH_TLS_SKIP_VERIFY", "accept any certificate of https:// targets", &c.TLSSkipVerify},
+		{"http2", "http2", "YJ_ISUCON_BENCH_HTTP2", "negotiate HTTP/2 with https:// targets", &c.HTTP2},
+		{"scoring_rules", "scoring-rules", "YJ_ISUCON_BENCH_SCORING_RULES", "scoring rule table (YAML), the built-in rules when empty", &c.ScoringRules},
+		{"scoring_mode", "scoring-mode", "YJ_ISUCON_BENCH_SCORING_MODE", "scoring mode (count or latency)", &c.ScoringMode},
+		{"latency_tiers", "latency-tiers", "YJ_ISUCON_BENCH_LATENCY_TIERS", "latency mode tiers as bound:multiplier, slower than the last bound scores 0", &c.LatencyTiers},
+		{"locale", "locale", "YJ_ISUCON_BENCH_LOCALE", "language of the failure messages (ja or en) for teams without one", &c.Locale},
+	}
+}
+
+// Current returns the parameters in effect
+func Current() Config {
+	return Config{
+		MaxWorkerCount:     MaxWorkerCount,
+		MaxCheckers:        MaxCheckers,
+		InitializeTimeout:  InitializeTimeout,
+		BenchTimeLimit:     BenchTimeLimit,
+		QueueCheckDuration: QueueCheckDuration,
+		RequestTimeout:     RequestTimeout,
+		LogFilePath:        LogFilePath,
+		LogFormat:          LogFormat,
+		LogLevel:           LogLevel,
+		LogMaxSize:         LogMaxSize,
+		LogMaxBackups:      LogMaxBackups,
+		ReportPath:         ReportPath,
+		TimelinePath:       TimelinePath,
+		ScenarioPath:       ScenarioPath,
+		LoadMode:           LoadMode,
+		RampWarmup:         RampWarmup,
+		RampInterval:       RampInterval,
+		RampStep:           RampStep,
+		RampMaxWorkers:     RampMaxWorkers,
+		RampErrorRate:      RampErrorRate,
+		RampWorkerBonus:    RampWorkerBonus,
+		Seed:               Seed,
+		MetricsAddr:        MetricsAddr,
+		MaxParallelBenches: MaxParallelBenches,
+		JobSource:          JobSource,
+		JobFile:            JobFile,
+		JobResultPath:      JobResultPath,
+		WorkerID:           WorkerID,
+		LeaseDuration:      LeaseDuration,
+		HeartbeatInterval:  HeartbeatInterval,
+		MaxAttempts:        MaxAttempts,
+		TranscriptSize:     TranscriptSize,
+
+		FailOnAssetMismatch:    FailOnAssetMismatch,
+		FailOnPostTimeout:      FailOnPostTimeout,
+		FailConsistencyRate:    FailConsistencyRate,
+		FailConsistencyMin:     FailConsistencyMin,
+		FailConsistencyActions: FailConsistencyActions,
+
+		VerifySample:   VerifySample,
+		VerifyTimeout:  VerifyTimeout,
+		VerifyPenalty:  VerifyPenalty,
+		VerifyFailRate: VerifyFailRate,
+
+		RestartAgentPort: RestartAgentPort,
+		RestartToken:     RestartToken,
+		RestartTimeout:   RestartTimeout,
+
+		CacheShared: CacheShared,
+
+		BrowserAssets: BrowserAssets,
+		AssetParallel: AssetParallel,
+
+		TLSCABundle:   TLSCABundle,
+		TLSSkipVerify: TLSSkipVerify,
+		HTTP2:         HTTP2,
+
+		ScoringRules: ScoringRules,
+		ScoringMode:  ScoringMode,
+		LatencyTiers: LatencyTiers,
+
+		Locale: Locale,
+	}
+}
+
+// Load parses args with fs and applies, in increasing priority, the config
+// file, the environment variables and the flags given on the command line.
+func Load(fs *flag.FlagSet, args []string) error {
+	c := Current()
+
+	path := fs.String("config", os.Getenv(ConfigFileEnv), "config file (YAML, or TOML with a .toml extension)")
+
+	ps := params(&c)
+	for _, p := range ps {
+		usage := fmt.Sprintf("%s (env %s)", p.usage, p.env)
+		// bools are registered as such so that a bare -flag sets them
+		if b, ok := p.ptr.(*bool); ok {
+			fs.Bool(p.flag, *b, usage)
+			continue
+		}
+		fs.String(p.flag, fmt.Sprint(valueOf(p.ptr)), usage)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if len(*path) != 0 {
+		if err := c.readFile(*path); err != nil {
+			return fmt.Errorf("config %s : %v", *path, err)
+		}
+	}
+
+	for _, p := range ps {
+		if val, ok := os.LookupEnv(p.env); ok {
+			if err := setValue(p.ptr, val); err != nil {
+				return fmt.Errorf("%s : %v", p.env, err)
+			}
+		}
+	}
+
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		for _, p := range ps {
+			if p.flag == f.Name && err == nil {
+				if e := setValue(p.ptr, f.Value.String()); e != nil {
+					err = fmt.Errorf("-%s : %v", p.flag, e)
+				}
+			}
+		}
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if err = c.validate(); err != nil {
+		return err
+	}
+
+	c.apply()
+
+	return nil
+}
+
+// readFile reads the config file at path, TOML when its extension is .toml
+// and YAML otherwise. Unknown keys are rejected in both.
+func (c *Config) readFile(path string) error {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if strings.ToLower(filepath.Ext(path)) != ".toml" {
+		return yaml.UnmarshalStrict(buf, c)
+	}
+
+	md, err := toml.Decode(string(buf), c)
+	if err != nil {
+		return err
+	}
+	if keys := md.Undecoded(); len(keys) != 0 {
+		return fmt.Errorf("unknown keys %v", keys)
+	}
+	return nil
+}
+
+func (c *Config) validate() error {
+	switch {
+	case c.MaxWorkerCount <= 0:
+		return errors.New("max_worker_count must be positive")
+	case c.MaxParallelBenches <= 0:
+		return errors.New("max_parallel_benches must be positive")
+	case c.MaxCheckers <= 0:
+		return errors.New("max_checkers must be positive")
+	case c.InitializeTimeout <= 0, c.BenchTimeLimit <= 0, c.QueueCheckDuration <= 0, c.RequestTimeout <= 0:
+		return errors.New("durations must be positive")
+	case len(c.LogFilePath) == 0:
+		return errors.New("log_file_path must not be empty")
+	case c.LogFormat != "text" && c.LogFormat != "json":
+		return fmt.Errorf("unknown log_format %q", c.LogFormat)
+	case c.LogLevel != "debug" && c.LogLevel != "info" && c.LogLevel != "warn" && c.LogLevel != "error":
+		return fmt.Errorf("unknown log_level %q", c.LogLevel)
+	case c.LogMaxSize < 0 || c.LogMaxBackups < 0:
+		return errors.New("log_max_size and log_max_backups must not be negative")
+	case c.LoadMode != LoadModeFixed && c.LoadMode != LoadModeRamp:
+		return fmt.Errorf("unknown load_mode %q", c.LoadMode)
+	case c.ScoringMode != ScoringModeCount && c.ScoringMode != ScoringModeLatency:
+		return fmt.Errorf("unknown scoring_mode %q", c.ScoringMode)
+	case c.Locale != LocaleJA && c.Locale != LocaleEN:
+		return fmt.Errorf("unknown locale %q", c.Locale)
+	case c.LoadMode == LoadModeRamp && (c.RampWarmup < 0 || c.RampInterval <= 0 || c.RampStep <= 0):
+		return errors.New("ramp_warmup, ramp_interval and ramp_step must be positive")
+	case c.LoadMode == LoadModeRamp && c.RampMaxWorkers < c.MaxWorkerCount:
+		return errors.New("ramp_max_workers must not be less than max_worker_count")
+	case c.RampErrorRate < 0 || c.RampErrorRate > 1:
+		return errors.New("ramp_error_rate must be between 0 and 1")
+	case c.JobSource != JobSourceMySQL && c.JobSource != JobSourceHTTP && c.JobSource != JobSourceFile:
+		return fmt.Errorf("unknown job_source %q", c.JobSource)
+	case c.JobSource == JobSourceFile && (len(c.JobFile) == 0 || len(c.JobResultPath) == 0):
+		return errors.New("job_file and job_result_path must not be empty")
+	case len(c.WorkerID) == 0:
+		return errors.New("worker_id must not be empty")
+	case c.HeartbeatInterval <= 0 || c.LeaseDuration < c.HeartbeatInterval*2:
+		return errors.New("lease_duration must be at least twice heartbeat_interval")
+	case c.LeaseDuration < time.Second:
+		return errors.New("lease_duration must be at least a second")
+	case c.MaxAttempts <= 0:
+		return errors.New("max_attempts must be positive")
+	case c.TranscriptSize < 0:
+		return errors.New("transcript_size must not be negative")
+	case c.FailConsistencyRate < 0 || c.FailConsistencyRate > 1:
+		return errors.New("fail_consistency_rate must be between 0 and 1")
+	case c.FailConsistencyMin < 0:
+		return errors.New("fail_consistency_min must not be negative")
+	case c.VerifySample < 0 || c.VerifyPenalty < 0:
+		return errors.New("verify_sample and verify_penalty must not be negative")
+	case c.VerifySample > 0 && c.VerifyTimeout <= 0:
+		return errors.New("verify_timeout must be positive")
+	case c.VerifyFailRate < 0 || c.VerifyFailRate > 1:
+		return errors.New("verify_fail_rate must be between 0 and 1")
+	case c.AssetParallel < 1:
+		return errors.New("asset_parallel must be positive")
+	case c.RestartAgentPort < 0 || c.RestartAgentPort > 65535:
+		return errors.New("restart_agent_port must be a port number")
+	case c.RestartAgentPort != 0 && c.RestartTimeout <= 0:
+		return errors.New("restart_timeout must be positive")
+	case c.RestartAgentPort != 0 && len(c.RestartToken) == 0:
+		return errors.New("restart_token is required with restart_agent_port")
+	}
+	return nil
+}
+
+func (c *Config) apply() {
+	MaxWorkerCount = c.MaxWorkerCount
+	MaxCheckers = c.MaxCheckers
+	InitializeTimeout = c.InitializeTimeout
+	BenchTimeLimit = c.BenchTimeLimit
+	QueueCheckDuration = c.QueueCheckDuration
+	RequestTimeout = c.RequestTimeout
+	LogFilePath = c.LogFilePath
+	LogFormat = c.LogFormat
+	LogLevel = c.LogLevel
+	LogMaxSize = c.LogMaxSize
+	LogMaxBackups = c.LogMaxBackups
+	ReportPath = c.ReportPath
+	TimelinePath = c.TimelinePath
+	ScenarioPath = c.ScenarioPath
+	LoadMode = c.LoadMode
+	RampWarmup = c.RampWarmup
+	RampInterval = c.RampInterval
+	RampStep = c.RampStep
+	RampMaxWorkers = c.RampMaxWorkers
+	RampErrorRate = c.RampErrorRate
+	RampWorkerBonus = c.RampWorkerBonus
+	Seed = c.Seed
+	MetricsAddr = c.MetricsAddr
+	MaxParallelBenches = c.MaxParallelBenches
+	JobSource = c.JobSource
+	JobFile = c.JobFile
+	JobResultPath = c.JobResultPath
+	WorkerID = c.WorkerID
+	LeaseDuration = c.LeaseDuration
+	HeartbeatInterval = c.HeartbeatInterval
+	MaxAttempts = c.MaxAttempts
+	TranscriptSize = c.TranscriptSize
+	FailOnAssetMismatch = c.FailOnAssetMismatch
+	FailOnPostTimeout = c.FailOnPostTimeout
+	FailConsistencyRate = c.FailConsistencyRate
+	FailConsistencyMin = c.FailConsistencyMin
+	FailConsistencyActions = c.FailConsistencyActions
+	VerifySample = c.VerifySample
+	VerifyTimeout = c.VerifyTimeout
+	VerifyPenalty = c.VerifyPenalty
+	VerifyFailRate = c.VerifyFailRate
+	RestartAgentPort = c.RestartAgentPort
+	RestartToken = c.RestartToken
+	RestartTimeout = c.RestartTimeout
+	CacheShared = c.CacheShared
+	BrowserAssets = c.BrowserAssets
+	AssetParallel = c.AssetParallel
+	TLSCABundle = c.TLSCABundle
+	TLSSkipVerify = c.TLSSkipVerify
+	HTTP2 = c.HTTP2
+	ScoringRules = c.ScoringRules
+	ScoringMode = c.ScoringMode
+	LatencyTiers = c.LatencyTiers
+	Locale = c.Locale
+}
+
+// JSON returns the config as JSON with human readable durations. Secrets
+// are masked since the JSON is logged and stored with each score.
+func (c Config) JSON() string {
+	if len(c.RestartToken) != 0 {
+		c.RestartToken = "********"
+	}
+
+	val := make(map[string]interface{})
+	for _, p := range params(&c) {
+		if d, ok := p.ptr.(*time.Duration); ok {
+			val[p.key] = d.String()
+			continue
+		}
+		val[p.key] = valueOf(p.ptr)
+	}
+	buf, err := json.Marshal(val)
+	if err != nil {
+		return ""
+	}
+	return string(buf)
+}
+
+func valueOf(ptr interface{}) interface{} {
+	switch v := ptr.(type) {
+	case *int:
+		return *v
+	case *int64:
+		return *v
+	case *float64:
+		return *v
+	case *time.Duration:
+		return *v
+	case *string:
+		return *v
+	case *bool:
+		return *v
+	}
+	return nil
+}
+
+func setValue(ptr interface{}, val string) error {
+	switch v := ptr.(type) {
+	case *int:
+		i, err := strconv.Atoi(val)
+		if err != nil {
+			return err
+		}
+		*v = i
+	case *int64:
+		i, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return err
+		}
+		*v = i
+	case *float64:
+		f, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return err
+		}
+		*v = f
+	case *time.Duration:
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return err
+		}
+		*v = d
+	case *string:
+		*v = val
+	case *bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		*v = b
+	}
+	return nil
+}
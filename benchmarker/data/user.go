@@ -5,7 +5,6 @@ import (
 	"math/rand"
 	"strings"
 	"sync"
-	"time"
 
 	"github.com/yahoojapan/yisucon/benchmarker/model"
 	"github.com/yahoojapan/yisucon/benchmarker/util"
@@ -16,7 +15,8 @@ var (
 	once    sync.Once
 )
 
-func GetAccounts() ([]*model.Account, error) {
+// GetAccounts returns the accounts shuffled by rnd
+func GetAccounts(rnd *rand.Rand) ([]*model.Account, error) {
 	once.Do(func() {
 		account = userNameReader()
 	})
@@ -25,7 +25,10 @@ func GetAccounts() ([]*model.Account, error) {
 		return nil, errors.New("user data not found")
 	}
 
-	return shuffleAccount(account), nil
+	accounts := make([]*model.Account, len(account))
+	copy(accounts, account)
+
+	return shuffleAccount(accounts, rnd), nil
 }
 
 func userNameReader() []*model.Account {
@@ -41,10 +44,9 @@ func userNameReader() []*model.Account {
 	return accounts
 }
 
-func shuffleAccount(accounts []*model.Account) []*model.Account {
-	rand.Seed(time.Now().UnixNano())
+func shuffleAccount(accounts []*model.Account, rnd *rand.Rand) []*model.Account {
 	for i := range accounts {
-		j := rand.Intn(i + 1)
+		j := rnd.Intn(i + 1)
 		accounts[i], accounts[j] = accounts[j], accounts[i]
 	}
 	return accounts
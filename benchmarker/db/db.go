@@ -1,17 +1,37 @@
 package db
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"sync"
 	"time"
 
+	"github.com/yahoojapan/yisucon/benchmarker/config"
+	"github.com/yahoojapan/yisucon/benchmarker/metrics"
 	"github.com/yahoojapan/yisucon/benchmarker/model"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/gocraft/dbr"
 )
 
+var (
+	// ErrQueueTaken is returned when another benchmarker claimed the job first
+	ErrQueueTaken = errors.New("queue already taken")
+	// ErrLeaseLost is returned when the lease of a job expired and the job was
+	// retried or aborted by someone else
+	ErrLeaseLost = errors.New("queue lease lost")
+)
+
+// Queue table status
+const (
+	StatusDone = iota
+	StatusStandby
+	StatusRunning
+	StatusAborted
+)
+
 type DB struct {
 	Type string
 	Host string
@@ -83,21 +103,23 @@ func (db DB) FetchQueue() (*model.TeamQueue, error) {
 
 	var queue *model.TeamQueue
 
-	//INFO : queue table status { 0: done, 1: standby, 2: running }
-	err = tx.Select("*").From("team_queue").Where(dbr.Eq("status", 1)).OrderBy("date").Limit(1).LoadStruct(&queue)
+	err = tx.Select("*").From("team_queue").Where(dbr.Eq("status", StatusStandby)).OrderBy("date").Limit(1).LoadStruct(&queue)
 
 	if err != nil {
 		return nil, err
 	}
 
-	result, err := tx.Update("queue").Set("status", 2).Where(dbr.And(dbr.Eq("team_id", queue.TeamID), dbr.Eq("status", 1))).Exec()
+	// claim by queue id only while the job is still standby, so that
+	// concurrent benchmarkers never start the same job twice
+	result, err := tx.UpdateBySql("UPDATE queue SET status = ?, worker_id = ?, lease_expires = NOW() + INTERVAL ? SECOND, attempts = attempts + 1 WHERE id = ? AND status = ?",
+		StatusRunning, config.WorkerID, leaseSeconds(), queue.QueueID.Int64, StatusStandby).Exec()
 
 	if err != nil {
 		return nil, err
 	}
 
 	if count, _ := result.RowsAffected(); count != 1 {
-		return nil, dbr.ErrNotSupported
+		return nil, ErrQueueTaken
 	}
 
 	err = tx.Commit()
@@ -106,11 +128,70 @@ func (db DB) FetchQueue() (*model.TeamQueue, error) {
 		return nil, err
 	}
 
+	queue.Attempts.Int64++
+
 	return queue, nil
 }
 
+var (
+	heartbeatDB *DB
+	heartbeatMu sync.Mutex
+)
+
+// heartbeatConn returns the connection pool shared by the heartbeats of all
+// running jobs, it is opened on first use and kept for the process lifetime
+func heartbeatConn() (*DB, error) {
+	defer heartbeatMu.Unlock()
+	heartbeatMu.Lock()
+
+	if heartbeatDB != nil {
+		return heartbeatDB, nil
+	}
+
+	db, err := NewDB()
+
+	if err != nil {
+		return nil, err
+	}
+
+	heartbeatDB = db
+
+	return db, nil
+}
+
+// RenewLease extends the lease of a running job claimed by this benchmarker
+func RenewLease(queueID int64) error {
+	db, err := heartbeatConn()
+
+	if err != nil {
+		return err
+	}
+
+	result, err := db.Conn.UpdateBySql("UPDATE queue SET lease_expires = NOW() + INTERVAL ? SECOND, date = date WHERE id = ? AND status = ? AND worker_id = ?",
+		leaseSeconds(), queueID, StatusRunning, config.WorkerID).Exec()
+
+	if err != nil {
+		return err
+	}
+
+	if count, _ := result.RowsAffected(); count != 1 {
+		return ErrLeaseLost
+	}
+
+	return nil
+}
+
+func leaseSeconds() int64 {
+	return int64(config.LeaseDuration / time.Second)
+}
+
+// expiredQueueChecker puts the running jobs whose lease expired back to
+// standby, or aborts them with a zero score once they used up their attempts.
+// Rows claimed before leases existed expire two minutes after the claim.
 func (db DB) expiredQueueChecker() {
 
+	const expired = "status = ? AND COALESCE(lease_expires, date + INTERVAL 2 MINUTE) < NOW()"
+
 	tx, err := db.Conn.Begin()
 
 	if err != nil {
@@ -119,7 +200,25 @@ func (db DB) expiredQueueChecker() {
 
 	defer tx.RollbackUnlessCommitted()
 
-	_, err = tx.Exec("UPDATE queue SET status = 0 WHERE status = 2 AND date < NOW() - INTERVAL 2 MINUTE")
+	reason := fmt.Sprintf("aborted : the benchmarker stopped responding %d times", config.MaxAttempts)
+
+	_, err = tx.Exec("INSERT INTO score (queue_id, score, message) SELECT id, 0, ? FROM queue WHERE "+expired+" AND attempts >= ?",
+		reason, StatusRunning, config.MaxAttempts)
+
+	if err != nil {
+		return
+	}
+
+	aborted, err := tx.Exec("UPDATE queue SET status = ?, worker_id = NULL, lease_expires = NULL, reason = ? WHERE "+expired+" AND attempts >= ?",
+		StatusAborted, reason, StatusRunning, config.MaxAttempts)
+
+	if err != nil {
+		return
+	}
+
+	// date = date keeps a retried job at its place in the queue
+	retried, err := tx.Exec("UPDATE queue SET status = ?, worker_id = NULL, lease_expires = NULL, reason = ?, date = date WHERE "+expired+" AND attempts < ?",
+		StatusStandby, "lease expired : retrying", StatusRunning, config.MaxAttempts)
 
 	if err != nil {
 		return
@@ -130,10 +229,19 @@ func (db DB) expiredQueueChecker() {
 	if err != nil {
 		return
 	}
+
+	if count, _ := aborted.RowsAffected(); count > 0 {
+		metrics.QueueExpired.WithLabelValues("aborted").Add(float64(count))
+	}
+
+	if count, _ := retried.RowsAffected(); count > 0 {
+		metrics.QueueExpired.WithLabelValues("retried").Add(float64(count))
+	}
 }
 
-// QueueChecker returns benchmark queue
-func QueueChecker(dur time.Duration) (*model.TeamQueue, error) {
+// QueueChecker returns benchmark queue. It polls every dur until a job is
+// claimed or ctx is done.
+func QueueChecker(ctx context.Context, dur time.Duration) (*model.TeamQueue, error) {
 
 	db, err := NewDB()
 
@@ -149,12 +257,20 @@ func QueueChecker(dur time.Duration) (*model.TeamQueue, error) {
 
 	for {
 		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
 		case <-ticker.C:
 			db.expiredQueueChecker()
 			queue, err := db.FetchQueue()
 			if queue != nil && err == nil {
+				metrics.QueuePolls.WithLabelValues("claimed").Inc()
 				return queue, nil
 			}
+			if err == dbr.ErrNotFound || err == ErrQueueTaken {
+				metrics.QueuePolls.WithLabelValues("empty").Inc()
+			} else {
+				metrics.QueuePolls.WithLabelValues("error").Inc()
+			}
 		}
 	}
 }
@@ -171,6 +287,22 @@ func SaveResult(teamID int64, score *model.Score) error {
 
 	score.CreateErrMessage()
 
+	var rep, transcript dbr.NullString
+
+	if score.Report != nil {
+		buf, err := score.Report.JSON()
+		if err != nil {
+			return err
+		}
+		rep = dbr.NewNullString(string(buf))
+
+		buf, err = score.Report.TranscriptJSON()
+		if err != nil {
+			return err
+		}
+		transcript = dbr.NewNullString(string(buf))
+	}
+
 	tx, err := db.Conn.Begin()
 
 	if err != nil {
@@ -179,17 +311,21 @@ func SaveResult(teamID int64, score *model.Score) error {
 
 	defer tx.RollbackUnlessCommitted()
 
-	result, err := tx.Update("queue").Set("status", 0).Where(dbr.Eq("team_id", teamID)).Exec()
+	// only the benchmarker holding the lease may close the job
+	result, err := tx.UpdateBySql("UPDATE queue SET status = ?, worker_id = NULL, lease_expires = NULL, reason = NULL WHERE id = ? AND team_id = ? AND status = ? AND worker_id = ?",
+		StatusDone, score.QueueID.Int64, teamID, StatusRunning, config.WorkerID).Exec()
 
 	if err != nil {
 		return err
 	}
 
-	if count, _ := result.RowsAffected(); count > 1 {
+	if count, _ := result.RowsAffected(); count == 0 {
+		return ErrLeaseLost
+	} else if count > 1 {
 		return errors.New("too many update request : may be bad logic")
 	}
 
-	_, err = tx.InsertInto("score").Columns("queue_id", "score", "message").Values(score.QueueID.Int64, score.Score.Int64, score.Message.String).Exec()
+	_, err = tx.InsertInto("score").Columns("queue_id", "score", "message", "status", "reason", "config", "report", "transcript", "rules_version").Values(score.QueueID.Int64, score.Score.Int64, score.Message.String, score.Status.String, score.Reason, score.Config.String, rep, transcript, score.RulesVersion).Exec()
 
 	if err != nil {
 		return err
@@ -0,0 +1,217 @@
+package failure
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+
+	"github.com/yahoojapan/yisucon/benchmarker/config"
+	"github.com/yahoojapan/yisucon/benchmarker/message"
+)
+
+// Kind is the category of a failure
+type Kind string
+
+// Kinds of failure of the target
+const (
+	ConnectionRefused Kind = "connection_refused"
+	// Connection is any other transport error: DNS, TLS, reset connections
+	Connection        Kind = "connection_error"
+	Timeout           Kind = "timeout"
+	HTTPStatus        Kind = "http_status"
+	ContentMismatch   Kind = "content_mismatch"
+	MissingElement    Kind = "missing_element"
+	AssetHashMismatch Kind = "asset_hash_mismatch"
+	// Unknown is an error that was not classified
+	Unknown Kind = "unknown"
+)
+
+// Error is a failure of the target with the context it happened in. Error()
+// is the Japanese message, Localize renders it in the language of a team.
+type Error struct {
+	Kind Kind
+	// URL and Status are those of the request that failed, Status is 0 when
+	// no response came back
+	URL    string
+	Status int
+	// Selector locates the element of the page that did not pass
+	Selector string
+	// Code keys the message in the catalogs of package message, Args are
+	// its arguments. Wrapped errors have no code and keep their message.
+	Code    string
+	Args    []interface{}
+	Message string
+	// Err is the underlying error, if any
+	Err error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Localize renders the message of e in locale
+func (e *Error) Localize(locale string) string {
+	if len(e.Code) == 0 {
+		return e.Message
+	}
+	return message.Get(locale, e.Code, e.Args...)
+}
+
+// New returns a failure of kind about the element at selector with the
+// message of code
+func New(kind Kind, selector, code string, args ...interface{}) *Error {
+	return &Error{
+		Kind:     kind,
+		Selector: selector,
+		Code:     code,
+		Args:     args,
+		Message:  message.Get(config.LocaleJA, code, args...),
+	}
+}
+
+// Request classifies the transport error err of a request to u
+func Request(u string, err error, code string, args ...interface{}) *Error {
+	e := New(transport(err), "", code, args...)
+	e.URL = u
+	e.Err = err
+
+	return e
+}
+
+// Status returns the failure of a request to u answered with status
+func Status(u string, status int, code string, args ...interface{}) *Error {
+	e := New(HTTPStatus, "", code, args...)
+	e.URL = u
+	e.Status = status
+
+	return e
+}
+
+// Asset returns the failure of the static file at u whose content differs
+func Asset(u, code string) *Error {
+	e := New(AssetHashMismatch, "", code)
+	e.URL = u
+
+	return e
+}
+
+// Wrap returns err as a failure of kind keeping its message
+func Wrap(kind Kind, err error) *Error {
+	return &Error{
+		Kind:    kind,
+		Message: err.Error(),
+		Err:     err,
+	}
+}
+
+// As returns err as a failure, if it is one
+func As(err error) (*Error, bool) {
+	e, ok := err.(*Error)
+	return e, ok
+}
+
+// KindOf returns the kind of err. Errors that are not failures are Unknown
+// unless they are network timeouts.
+func KindOf(err error) Kind {
+	if e, ok := As(err); ok {
+		return e.Kind
+	}
+	if err != nil && isTimeout(err) {
+		return Timeout
+	}
+	return Unknown
+}
+
+// WithMessage returns a copy of err, classified like it, with the message
+// of code
+func WithMessage(err error, code string, args ...interface{}) *Error {
+	e, ok := As(err)
+	if !ok {
+		e = Wrap(KindOf(err), err)
+	}
+
+	c := *e
+	c.Code = code
+	c.Args = args
+	c.Message = message.Get(config.LocaleJA, code, args...)
+
+	return &c
+}
+
+// Localize renders the message of err in locale. Errors that are not
+// failures keep their message.
+func Localize(err error, locale string) string {
+	if e, ok := As(err); ok {
+		return e.Localize(locale)
+	}
+	return err.Error()
+}
+
+// WithRequest returns a copy of err with the URL and status of the request
+// it happened on when it does not carry them already. Failures are copied so
+// that shared ones are never changed.
+func WithRequest(err error, u string, status int) error {
+	e, ok := As(err)
+	if !ok {
+		e = Wrap(KindOf(err), err)
+	}
+
+	c := *e
+	if len(c.URL) == 0 {
+		c.URL = u
+	}
+	if c.Status == 0 {
+		c.Status = status
+	}
+
+	return &c
+}
+
+// transport returns the kind of the transport error err. A cancelled request
+// is not the fault of the target and stays Unknown.
+func transport(err error) Kind {
+	switch {
+	case isTimeout(err):
+		return Timeout
+	case isRefused(err):
+		return ConnectionRefused
+	case cause(err) == context.Canceled:
+		return Unknown
+	}
+	return Connection
+}
+
+// cause unwraps the url, net and syscall errors around err
+func cause(err error) error {
+	for {
+		switch e := err.(type) {
+		case *url.Error:
+			err = e.Err
+		case *net.OpError:
+			err = e.Err
+		case *os.SyscallError:
+			err = e.Err
+		default:
+			return err
+		}
+	}
+}
+
+func isRefused(err error) bool {
+	return cause(err) == syscall.ECONNREFUSED
+}
+
+func isTimeout(err error) bool {
+	if uerr, ok := err.(*url.Error); ok {
+		err = uerr.Err
+	}
+
+	if err == context.DeadlineExceeded {
+		return true
+	}
+
+	nerr, ok := err.(net.Error)
+	return ok && nerr.Timeout()
+}
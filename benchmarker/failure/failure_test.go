@@ -0,0 +1,53 @@
+package failure
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+)
+
+// timeoutError is a net.Error that timed out
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestRequest(t *testing.T) {
+	// dial wraps err the way net/http reports a failed dial
+	dial := func(err error) error {
+		return &url.Error{Op: "Get", URL: "http://target/", Err: &net.OpError{Op: "dial", Net: "tcp", Err: err}}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want Kind
+	}{
+		{"refused", dial(os.NewSyscallError("connect", syscall.ECONNREFUSED)), ConnectionRefused},
+		{"reset", dial(os.NewSyscallError("read", syscall.ECONNRESET)), Connection},
+		{"dns", dial(&net.DNSError{Err: "no such host", Name: "target"}), Connection},
+		{"tls", &url.Error{Op: "Get", URL: "https://target/", Err: x509.UnknownAuthorityError{}}, Connection},
+		{"eof", &url.Error{Op: "Get", URL: "http://target/", Err: io.EOF}, Connection},
+		{"net timeout", dial(timeoutError{}), Timeout},
+		{"deadline", &url.Error{Op: "Get", URL: "http://target/", Err: context.DeadlineExceeded}, Timeout},
+		{"cancelled", &url.Error{Op: "Get", URL: "http://target/", Err: context.Canceled}, Unknown},
+		{"other", errors.New("broken"), Connection},
+	}
+
+	for _, tt := range tests {
+		e := Request("http://target/", tt.err, "request.failed")
+		if e.Kind != tt.want {
+			t.Errorf("%s : Request(%v).Kind = %s, want %s", tt.name, tt.err, e.Kind, tt.want)
+		}
+		if e.Err != tt.err || e.URL != "http://target/" {
+			t.Errorf("%s : Request() lost the error or the URL : %+v", tt.name, e)
+		}
+	}
+}
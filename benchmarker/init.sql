@@ -14,6 +14,7 @@ CREATE TABLE IF NOT EXISTS `isucon`.`team` (
   `host` VARCHAR(255) NOT NULL,
   `best_score` INT(11) UNSIGNED ZEROFILL NOT NULL,
   `lang` VARCHAR(45) NULL DEFAULT NULL,
+  `locale` VARCHAR(8) NULL DEFAULT NULL,
   PRIMARY KEY (`id`),
   UNIQUE INDEX `id_UNIQUE` (`id` ASC),
 ENGINE = InnoDB
@@ -24,6 +25,10 @@ CREATE TABLE IF NOT EXISTS `isucon`.`queue` (
   `id` INT(11) UNSIGNED NOT NULL AUTO_INCREMENT,
   `team_id` INT(11) UNSIGNED NOT NULL,
   `status` INT(10) UNSIGNED ZEROFILL NOT NULL,
+  `worker_id` VARCHAR(255) NULL DEFAULT NULL,
+  `lease_expires` DATETIME NULL DEFAULT NULL,
+  `attempts` INT(10) UNSIGNED NOT NULL DEFAULT 0,
+  `reason` TEXT NULL DEFAULT NULL,
   `date` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
   PRIMARY KEY (`id`),
   UNIQUE INDEX `id_UNIQUE` (`id` ASC),
@@ -42,6 +47,12 @@ CREATE TABLE IF NOT EXISTS `isucon`.`score` (
   `queue_id` INT(11) UNSIGNED NOT NULL,
   `score` INT(11) UNSIGNED ZEROFILL NOT NULL,
   `message` LONGTEXT NOT NULL,
+  `status` VARCHAR(16) NOT NULL DEFAULT 'PASS',
+  `reason` TEXT NULL DEFAULT NULL,
+  `config` TEXT NULL DEFAULT NULL,
+  `report` LONGTEXT NULL DEFAULT NULL,
+  `transcript` LONGTEXT NULL DEFAULT NULL,
+  `rules_version` VARCHAR(32) NULL DEFAULT NULL,
   `date` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
   PRIMARY KEY (`id`),
   UNIQUE INDEX `id_UNIQUE` (`id` ASC),
@@ -73,11 +84,11 @@ DEFAULT CHARACTER SET = utf8;
 
 USE `isucon` ;
 
-CREATE TABLE IF NOT EXISTS `isucon`.`team_queue` (`team_id` INT, `queue_id` INT, `host` INT, `status` INT, `date` INT);
+CREATE TABLE IF NOT EXISTS `isucon`.`team_queue` (`team_id` INT, `queue_id` INT, `host` INT, `status` INT, `attempts` INT, `locale` INT, `date` INT);
 
 DROP TABLE IF EXISTS `isucon`.`team_queue`;
 USE `isucon`;
-CREATE VIEW `isucon`.`team_queue` AS select `t`.`id` AS `team_id`,`q`.`id` AS `queue_id`,`t`.`host` AS `host`,`q`.`status` AS `status`,`q`.`date` AS `date` from (`isucon`.`queue` `q` join `isucon`.`team` `t` on((`t`.`id` = `q`.`team_id`))) order by `q`.`date`;
+CREATE VIEW `isucon`.`team_queue` AS select `t`.`id` AS `team_id`,`q`.`id` AS `queue_id`,`t`.`host` AS `host`,`q`.`status` AS `status`,`q`.`attempts` AS `attempts`,`t`.`locale` AS `locale`,`q`.`date` AS `date` from (`isucon`.`queue` `q` join `isucon`.`team` `t` on((`t`.`id` = `q`.`team_id`))) order by `q`.`date`;
 
 SET SQL_MODE=@OLD_SQL_MODE;
 SET FOREIGN_KEY_CHECKS=@OLD_FOREIGN_KEY_CHECKS;
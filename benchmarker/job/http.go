@@ -0,0 +1,134 @@
+package job
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/yahoojapan/yisucon/benchmarker/config"
+	"github.com/yahoojapan/yisucon/benchmarker/metrics"
+	"github.com/yahoojapan/yisucon/benchmarker/model"
+)
+
+// HTTP pulls jobs from the portal, which handles the leases like the mysql
+// source does. Every request carries worker={worker_id}, lease={seconds}
+// and max_attempts.
+//
+//	GET  /api/queue/next         200 with a Job, or 204 when the queue is empty
+//	POST /api/queue/{id}/heartbeat  extends the lease, 409 when it is lost
+//	POST /api/queue/{id}/result     Result as JSON, 409 when the lease is lost
+type HTTP struct {
+	host   string
+	dur    time.Duration
+	client *http.Client
+}
+
+// NewHTTP returns a source polling the portal at host every dur
+func NewHTTP(host string, dur time.Duration) *HTTP {
+	return &HTTP{
+		host: host,
+		dur:  dur,
+		client: &http.Client{
+			Timeout: time.Second * 10,
+		},
+	}
+}
+
+func (h *HTTP) Next(ctx context.Context) (*model.TeamQueue, error) {
+	ticker := time.NewTicker(h.dur)
+
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+			j, err := h.fetch(ctx)
+			switch {
+			case err != nil:
+				metrics.QueuePolls.WithLabelValues("error").Inc()
+			case j == nil:
+				metrics.QueuePolls.WithLabelValues("empty").Inc()
+			default:
+				metrics.QueuePolls.WithLabelValues("claimed").Inc()
+				return j.queue(), nil
+			}
+		}
+	}
+}
+
+func (h *HTTP) fetch(ctx context.Context) (*Job, error) {
+	req, err := http.NewRequest(http.MethodGet, h.url("next"), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := h.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return nil, nil
+	case http.StatusOK:
+	default:
+		return nil, fmt.Errorf("portal returned %s", resp.Status)
+	}
+
+	j := new(Job)
+
+	if err = json.NewDecoder(resp.Body).Decode(j); err != nil {
+		return nil, err
+	}
+
+	return j, nil
+}
+
+func (h *HTTP) Renew(q *model.TeamQueue) error {
+	return h.post(h.url(fmt.Sprintf("%d/heartbeat", q.QueueID.Int64)), nil)
+}
+
+func (h *HTTP) Done(q *model.TeamQueue, score *model.Score) error {
+	buf := new(bytes.Buffer)
+
+	if err := writeResult(buf, newResult(q, score)); err != nil {
+		return err
+	}
+
+	return h.post(h.url(fmt.Sprintf("%d/result", q.QueueID.Int64)), buf)
+}
+
+func (h *HTTP) url(path string) string {
+	q := url.Values{}
+	q.Set("worker", config.WorkerID)
+	q.Set("lease", fmt.Sprint(int64(config.LeaseDuration/time.Second)))
+	q.Set("max_attempts", fmt.Sprint(config.MaxAttempts))
+
+	return fmt.Sprintf("http://%s/api/queue/%s?%s", h.host, path, q.Encode())
+}
+
+func (h *HTTP) post(u string, body io.Reader) error {
+	resp, err := h.client.Post(u, "application/json", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusConflict {
+		return ErrLeaseLost
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("portal returned %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	"github.com/yahoojapan/yisucon/benchmarker/db"
+	"github.com/yahoojapan/yisucon/benchmarker/model"
+)
+
+// MySQL is the team_queue table of the contest DB
+type MySQL struct {
+	dur time.Duration
+}
+
+// NewMySQL returns a source polling the queue every dur
+func NewMySQL(dur time.Duration) *MySQL {
+	return &MySQL{
+		dur: dur,
+	}
+}
+
+func (m *MySQL) Next(ctx context.Context) (*model.TeamQueue, error) {
+	return db.QueueChecker(ctx, m.dur)
+}
+
+func (m *MySQL) Renew(q *model.TeamQueue) error {
+	return db.RenewLease(q.QueueID.Int64)
+}
+
+func (m *MySQL) Done(q *model.TeamQueue, score *model.Score) error {
+	return db.SaveResult(q.TeamID.Int64, score)
+}
@@ -0,0 +1,115 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gocraft/dbr"
+
+	"github.com/yahoojapan/yisucon/benchmarker/config"
+	"github.com/yahoojapan/yisucon/benchmarker/db"
+	"github.com/yahoojapan/yisucon/benchmarker/model"
+	"github.com/yahoojapan/yisucon/benchmarker/report"
+)
+
+// Source hands out benchmark jobs and stores their results
+type Source interface {
+	// Next blocks until a job is claimed or ctx is done. It returns io.EOF
+	// when the source will never have another job.
+	Next(ctx context.Context) (*model.TeamQueue, error)
+	// Renew extends the lease of the running job q. It returns ErrLeaseLost
+	// when the job no longer belongs to this benchmarker.
+	Renew(q *model.TeamQueue) error
+	// Done stores the result of the job q
+	Done(q *model.TeamQueue, score *model.Score) error
+}
+
+// ErrLeaseLost is returned by Renew and Done when the lease of the job
+// expired and the job was retried or aborted
+var ErrLeaseLost = db.ErrLeaseLost
+
+// New returns the source selected by config.JobSource
+func New() (Source, error) {
+	switch config.JobSource {
+	case config.JobSourceMySQL:
+		return NewMySQL(config.QueueCheckDuration), nil
+	case config.JobSourceHTTP:
+		return NewHTTP(config.PortalHost, config.QueueCheckDuration), nil
+	case config.JobSourceFile:
+		var w io.Writer = os.Stdout
+		if config.JobResultPath != "-" {
+			f, err := os.OpenFile(config.JobResultPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			if err != nil {
+				return nil, err
+			}
+			w = f
+		}
+		if config.JobFile == "-" {
+			return NewFile(os.Stdin, w)
+		}
+		f, err := os.Open(config.JobFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return NewFile(f, w)
+	}
+	return nil, fmt.Errorf("unknown job source %q", config.JobSource)
+}
+
+// Job is the wire format of a job for the HTTP and file sources
+type Job struct {
+	TeamID   int64  `json:"team_id"`
+	QueueID  int64  `json:"queue_id"`
+	Host     string `json:"host"`
+	Attempts int64  `json:"attempts"`
+	// Locale is the language of the team, config.Locale when empty
+	Locale string `json:"locale,omitempty"`
+}
+
+func (j *Job) queue() *model.TeamQueue {
+	return &model.TeamQueue{
+		TeamID:   dbr.NewNullInt64(j.TeamID),
+		QueueID:  dbr.NewNullInt64(j.QueueID),
+		Host:     dbr.NewNullString(j.Host),
+		Attempts: dbr.NewNullInt64(j.Attempts),
+		Locale:   dbr.NewNullString(j.Locale),
+	}
+}
+
+// Result is the wire format of a result for the HTTP and file sources
+type Result struct {
+	TeamID  int64          `json:"team_id"`
+	QueueID int64          `json:"queue_id"`
+	Score   int64          `json:"score"`
+	Message string         `json:"message"`
+	Status  string         `json:"status"`
+	Reason  string         `json:"reason,omitempty"`
+	Config  string         `json:"config"`
+	Report  *report.Report `json:"report,omitempty"`
+
+	RulesVersion string `json:"rules_version,omitempty"`
+}
+
+func newResult(q *model.TeamQueue, score *model.Score) *Result {
+	score.CreateErrMessage()
+	return &Result{
+		TeamID:  q.TeamID.Int64,
+		QueueID: q.QueueID.Int64,
+		Score:   score.Score.Int64,
+		Message: score.Message.String,
+		Status:  score.Status.String,
+		Reason:  score.Reason.String,
+		Config:  score.Config.String,
+		Report:  score.Report,
+
+		RulesVersion: score.RulesVersion.String,
+	}
+}
+
+func writeResult(w io.Writer, res *Result) error {
+	return json.NewEncoder(w).Encode(res)
+}
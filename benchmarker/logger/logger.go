@@ -1,18 +1,26 @@
 package logger
 
 import (
+	"fmt"
 	"io"
-	"log"
 	"os"
-	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/yahoojapan/yisucon/benchmarker/config"
 )
 
+// Logger writes leveled entries with fields. Loggers derived by With share
+// the output of the logger returned by GetLogger.
 type Logger struct {
-	LogFile *os.File
-	wg      *sync.WaitGroup
+	s      *sink
+	fields []field
+}
+
+type field struct {
+	key string
+	val interface{}
 }
 
 var (
@@ -20,62 +28,128 @@ var (
 	once   sync.Once
 )
 
+// GetLogger returns the root logger configured from config. Entries go to
+// Console and to LogFilePath, which rotates every LogMaxSize MB.
 func GetLogger() *Logger {
-	var err error
 	once.Do(func() {
-		logger = &Logger{
-			wg: new(sync.WaitGroup),
+		level, err := ParseLevel(config.LogLevel)
+		if err != nil {
+			level = LevelInfo
 		}
 
-		if _, err = os.Stat(config.LogFilePath); err != nil {
-			if _, err = os.Stat(filepath.Dir(config.LogFilePath)); err != nil {
-				os.MkdirAll(filepath.Dir(config.LogFilePath), 0755)
-			}
-			file, err := os.Create(config.LogFilePath)
-			if err != nil {
-				return
-			}
-			file.Close()
-		}
+		var (
+			w    = Console()
+			file io.Closer
+		)
 
-		logger.LogFile, err = os.OpenFile(config.LogFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0755)
+		rot, err := newRotator(config.LogFilePath, int64(config.LogMaxSize)<<20, config.LogMaxBackups)
+		if err == nil {
+			w, file = io.MultiWriter(rot, Console()), rot
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
 
+		logger = &Logger{
+			s: newSink(w, file, config.LogFormat, level),
+		}
 	})
 
-	if err == nil {
-		log.SetOutput(io.MultiWriter(logger.LogFile, os.Stdout))
+	return logger
+}
+
+// Console is where the human readable output goes: stdout, or stderr when
+// the report, the timeline or the results of the file job source are
+// written to stdout so that they stay parsable
+func Console() io.Writer {
+	if config.ReportPath == "-" || config.TimelinePath == "-" {
+		return os.Stderr
+	}
+	if config.JobSource == config.JobSourceFile && config.JobResultPath == "-" {
+		return os.Stderr
 	}
+	return os.Stdout
+}
 
-	log.SetFlags(log.Ldate | log.Ltime)
+// With returns a logger adding key=val to every entry
+func (l *Logger) With(key string, val interface{}) *Logger {
+	fields := make([]field, len(l.fields), len(l.fields)+1)
+	copy(fields, l.fields)
 
-	return logger
+	return &Logger{
+		s:      l.s,
+		fields: append(fields, field{key, val}),
+	}
+}
+
+func (l *Logger) log(level Level, msg string) {
+	if level < l.s.level {
+		return
+	}
+	l.s.emit(&entry{
+		time:   time.Now(),
+		level:  level,
+		msg:    strings.TrimRight(msg, "\n"),
+		fields: l.fields,
+	})
+}
+
+func (l *Logger) Debug(v ...interface{}) {
+	l.log(LevelDebug, fmt.Sprint(v...))
+}
+
+func (l *Logger) Debugf(format string, v ...interface{}) {
+	l.log(LevelDebug, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) Info(v ...interface{}) {
+	l.log(LevelInfo, fmt.Sprint(v...))
+}
+
+func (l *Logger) Infof(format string, v ...interface{}) {
+	l.log(LevelInfo, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) Warn(v ...interface{}) {
+	l.log(LevelWarn, fmt.Sprint(v...))
+}
+
+func (l *Logger) Warnf(format string, v ...interface{}) {
+	l.log(LevelWarn, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) Error(v ...interface{}) {
+	l.log(LevelError, fmt.Sprint(v...))
+}
+
+func (l *Logger) Errorf(format string, v ...interface{}) {
+	l.log(LevelError, fmt.Sprintf(format, v...))
+}
+
+// Println logs at info level
+func (l *Logger) Println(v ...interface{}) {
+	l.log(LevelInfo, fmt.Sprint(v...))
 }
 
-func (l Logger) Println(val interface{}) {
-	l.wg.Add(1)
-	go func() {
-		log.Println(val)
-		l.wg.Done()
-	}()
+// Printf logs at info level
+func (l *Logger) Printf(format string, v ...interface{}) {
+	l.log(LevelInfo, fmt.Sprintf(format, v...))
 }
 
-func (l Logger) Printf(format string, val interface{}) {
-	l.wg.Add(1)
-	go func() {
-		log.Printf(format, val)
-		l.wg.Done()
-	}()
+// Fatalln logs at fatal level, flushes every pending entry and exits
+func (l *Logger) Fatalln(v ...interface{}) {
+	l.log(LevelFatal, fmt.Sprint(v...))
+	l.s.close()
+	os.Exit(1)
 }
 
-func (l Logger) Fatalln(val interface{}) {
-	l.wg.Add(1)
-	go func() {
-		log.Fatalln(val)
-		l.wg.Done()
-	}()
+// Fatalf logs at fatal level, flushes every pending entry and exits
+func (l *Logger) Fatalf(format string, v ...interface{}) {
+	l.log(LevelFatal, fmt.Sprintf(format, v...))
+	l.s.close()
+	os.Exit(1)
 }
 
+// Close flushes every pending entry and closes the log file
 func (l *Logger) Close() error {
-	l.wg.Wait()
-	return l.LogFile.Close()
+	return l.s.close()
 }
@@ -0,0 +1,137 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// rotator is a log file renamed to path.1, path.2, ... once it grows past
+// max bytes. It is only written from the sink goroutine, which hands it whole
+// entries so that no line spans two files.
+type rotator struct {
+	path    string
+	max     int64
+	backups int
+	file    *os.File
+	size    int64
+}
+
+func newRotator(path string, max int64, backups int) (*rotator, error) {
+	r := &rotator{
+		path:    path,
+		max:     max,
+		backups: backups,
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *rotator) open() error {
+	file, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	r.file = file
+	r.size = info.Size()
+
+	return nil
+}
+
+// Write never fails so that the console, written next by the sink, keeps the
+// logs: once the file cannot be written or reopened the error is reported on
+// stderr and the file is dropped. p holds whole lines, the file is rotated
+// between two of them.
+func (r *rotator) Write(p []byte) (int, error) {
+	n := len(p)
+
+	for len(p) > 0 && r.file != nil {
+		chunk := p
+
+		if r.max > 0 && r.size+int64(len(p)) > r.max {
+			// the lines that still fit, or the first one in an empty file
+			i := -1
+			if room := r.max - r.size; room > 0 {
+				i = bytes.LastIndexByte(p[:room], '\n')
+			}
+
+			if i < 0 && r.size > 0 {
+				if err := r.rotate(); err != nil {
+					r.fail(err)
+				}
+				continue
+			}
+
+			if i < 0 {
+				if i = bytes.IndexByte(p, '\n'); i < 0 {
+					i = len(p) - 1
+				}
+			}
+
+			chunk = p[:i+1]
+		}
+
+		m, err := r.file.Write(chunk)
+		r.size += int64(m)
+
+		if err != nil {
+			r.fail(err)
+		}
+
+		p = p[len(chunk):]
+	}
+
+	return n, nil
+}
+
+func (r *rotator) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+
+	r.file = nil
+
+	if r.backups <= 0 {
+		os.Remove(r.path)
+	} else {
+		for i := r.backups - 1; i > 0; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		}
+		os.Rename(r.path, r.path+".1")
+	}
+
+	return r.open()
+}
+
+// fail stops writing to the log file after err
+func (r *rotator) fail(err error) {
+	fmt.Fprintf(os.Stderr, "logger : %v, logging to the console only\n", err)
+
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+}
+
+func (r *rotator) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Close()
+}
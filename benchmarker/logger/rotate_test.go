@@ -0,0 +1,87 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotateWholeLines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "bench.log")
+
+	rot, err := newRotator(path, 1<<10, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newSink(rot, rot, FormatText, LevelDebug)
+
+	for i := 0; i < 100; i++ {
+		s.emit(&entry{time: time.Now(), level: LevelInfo, msg: fmt.Sprintf("line %03d %s", i, strings.Repeat("x", 40))})
+	}
+
+	if err = s.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2", path + ".3"} {
+		buf, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(buf) > 1<<10 {
+			t.Errorf("%s : %d bytes, want at most %d", name, len(buf), 1<<10)
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(string(buf), "\n"), "\n") {
+			if !strings.Contains(line, "INFO  line ") || !strings.HasSuffix(line, "xxxx") {
+				t.Errorf("%s : broken line %q", name, line)
+			}
+		}
+	}
+}
+
+func TestRotateReopenError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rot, err := newRotator(filepath.Join(dir, "logs", "bench.log"), 1<<10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the rotation cannot reopen the file once its directory is gone
+	if err = os.RemoveAll(filepath.Join(dir, "logs")); err != nil {
+		t.Fatal(err)
+	}
+
+	console := new(bytes.Buffer)
+
+	s := newSink(io.MultiWriter(rot, console), rot, FormatText, LevelDebug)
+
+	for i := 0; i < 100; i++ {
+		s.emit(&entry{time: time.Now(), level: LevelInfo, msg: fmt.Sprintf("line %03d %s", i, strings.Repeat("x", 40))})
+	}
+
+	if err = s.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := strings.Count(console.String(), "\n"); n != 100 {
+		t.Errorf("the console got %d lines, want 100", n)
+	}
+}
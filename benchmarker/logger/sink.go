@@ -0,0 +1,176 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+
+	timeFormat = "2006/01/02 15:04:05"
+	queueSize  = 1024
+)
+
+type entry struct {
+	time   time.Time
+	level  Level
+	msg    string
+	fields []field
+}
+
+// sink serializes entries through a single goroutine so that they are
+// written in the order they were logged
+type sink struct {
+	l      *sync.RWMutex
+	closed bool
+	queue  chan *entry
+	done   chan struct{}
+	w      *bufio.Writer
+	file   io.Closer
+	format string
+	level  Level
+}
+
+func newSink(w io.Writer, file io.Closer, format string, level Level) *sink {
+	s := &sink{
+		l:      new(sync.RWMutex),
+		queue:  make(chan *entry, queueSize),
+		done:   make(chan struct{}),
+		w:      bufio.NewWriter(w),
+		file:   file,
+		format: format,
+		level:  level,
+	}
+
+	go s.run()
+
+	return s
+}
+
+func (s *sink) emit(e *entry) {
+	s.l.RLock()
+	defer s.l.RUnlock()
+
+	if s.closed {
+		// late entries after Close still reach the console
+		os.Stderr.Write(s.encode(e))
+		return
+	}
+
+	s.queue <- e
+}
+
+func (s *sink) run() {
+	defer close(s.done)
+
+	for e := range s.queue {
+		buf := s.encode(e)
+		// flush before an entry that does not fit so that the writers,
+		// the log rotation included, only ever see whole lines
+		if len(buf) > s.w.Available() {
+			s.w.Flush()
+		}
+		s.w.Write(buf)
+		// flush once the backlog is drained to keep the console responsive
+		if len(s.queue) == 0 {
+			s.w.Flush()
+		}
+	}
+
+	s.w.Flush()
+}
+
+func (s *sink) close() error {
+	s.l.Lock()
+	if s.closed {
+		s.l.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.queue)
+	s.l.Unlock()
+
+	<-s.done
+
+	if s.file != nil {
+		return s.file.Close()
+	}
+
+	return nil
+}
+
+func (s *sink) encode(e *entry) []byte {
+	if s.format == FormatJSON {
+		return encodeJSON(e)
+	}
+	return encodeText(e)
+}
+
+func encodeText(e *entry) []byte {
+	buf := new(bytes.Buffer)
+
+	fmt.Fprintf(buf, "%s %-5s %s", e.time.Format(timeFormat), e.level, e.msg)
+
+	for _, f := range e.fields {
+		val := fmt.Sprint(value(f.val))
+		if strings.ContainsAny(val, " =\"\n") {
+			val = fmt.Sprintf("%q", val)
+		}
+		fmt.Fprintf(buf, " %s=%s", f.key, val)
+	}
+
+	buf.WriteByte('\n')
+
+	return buf.Bytes()
+}
+
+func encodeJSON(e *entry) []byte {
+	buf := new(bytes.Buffer)
+
+	// written by hand to keep time, level and msg ahead of the fields
+	buf.WriteString(`{"time":`)
+	writeJSON(buf, e.time.Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJSON(buf, strings.ToLower(e.level.String()))
+	buf.WriteString(`,"msg":`)
+	writeJSON(buf, e.msg)
+
+	for _, f := range e.fields {
+		buf.WriteByte(',')
+		writeJSON(buf, f.key)
+		buf.WriteByte(':')
+		writeJSON(buf, value(f.val))
+	}
+
+	buf.WriteString("}\n")
+
+	return buf.Bytes()
+}
+
+func writeJSON(buf *bytes.Buffer, v interface{}) {
+	val, err := json.Marshal(v)
+	if err != nil {
+		val, _ = json.Marshal(fmt.Sprint(v))
+	}
+	buf.Write(val)
+}
+
+// value renders errors and durations as strings in both formats
+func value(v interface{}) interface{} {
+	switch val := v.(type) {
+	case error:
+		return val.Error()
+	case time.Duration:
+		return val.String()
+	}
+	return v
+}
@@ -1,37 +1,251 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/yahoojapan/yisucon/benchmarker/agent"
+	"github.com/yahoojapan/yisucon/benchmarker/checker"
 	"github.com/yahoojapan/yisucon/benchmarker/config"
+	"github.com/yahoojapan/yisucon/benchmarker/job"
 	"github.com/yahoojapan/yisucon/benchmarker/logger"
+	"github.com/yahoojapan/yisucon/benchmarker/metrics"
+	"github.com/yahoojapan/yisucon/benchmarker/report"
 	"github.com/yahoojapan/yisucon/benchmarker/runner"
+	"github.com/yahoojapan/yisucon/benchmarker/score"
+	"github.com/yahoojapan/yisucon/benchmarker/session"
 )
 
 func main() {
 
+	if len(os.Args) > 1 && os.Args[1] == "run" {
+		os.Exit(standalone(os.Args[2:]))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "agent" {
+		os.Exit(restartAgent(os.Args[2:]))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "rescore" {
+		os.Exit(rescore(os.Args[2:]))
+	}
+
+	if err := load(flag.CommandLine, os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
 	l := logger.GetLogger()
 
 	defer func() {
 		if err := recover(); err != nil {
-			l.Println(err)
+			l.Error(err)
+		}
+		if err := l.Close(); err != nil {
+			log.Fatalln(err)
 		}
-		log.Fatalln(l.Close())
 	}()
 
-	if strings.Contains(config.PortalHost, "localhost") || config.PortalHost == "" {
-		l.Fatalln(errors.New("Invalide PortalHost"))
+	if config.JobSource != config.JobSourceFile {
+		if strings.Contains(config.PortalHost, "localhost") || config.PortalHost == "" {
+			l.Fatalln(errors.New("Invalide PortalHost"))
+		}
+
+		_, err := http.DefaultClient.Get(fmt.Sprintf("http://%s", config.PortalHost))
+
+		if err != nil {
+			l.Fatalln(err)
+		}
 	}
 
-	_, err := http.DefaultClient.Get(fmt.Sprintf("http://%s", config.PortalHost))
+	if len(config.MetricsAddr) != 0 {
+		go func() {
+			l.Error(metrics.Serve(config.MetricsAddr))
+		}()
+	}
+
+	src, err := job.New()
 
 	if err != nil {
 		l.Fatalln(err)
 	}
 
-	l.Fatalln(runner.Run())
+	if err = runner.Run(shutdownContext(), src); err != nil {
+		l.Fatalln(err)
+	}
+
+	l.Println("runner : stopped")
+}
+
+// load parses the benchmark parameters in args with fs and loads the
+// scenario, TLS and scoring settings they name
+func load(fs *flag.FlagSet, args []string) error {
+	if err := config.Load(fs, args); err != nil {
+		return err
+	}
+
+	if err := checker.LoadScenario(config.ScenarioPath); err != nil {
+		return err
+	}
+
+	if err := session.LoadTLS(config.TLSCABundle, config.TLSSkipVerify); err != nil {
+		return err
+	}
+
+	if err := score.LoadRules(config.ScoringRules); err != nil {
+		return err
+	}
+
+	return score.SetMode(config.ScoringMode, config.LatencyTiers)
+}
+
+// standalone benchmarks a single target once without the portal and queue DB
+func standalone(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	target := fs.String("target", "", "benchmark target host (e.g. 127.0.0.1:8080 or https://example.com)")
+
+	if err := load(fs, args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	if *target == "" {
+		fmt.Fprintln(os.Stderr, "benchmarker run: -target is required")
+		fs.Usage()
+		return 2
+	}
+
+	l := logger.GetLogger()
+	defer l.Close()
+
+	result := runner.RunStandalone(shutdownContext(), *target)
+
+	out := logger.Console()
+
+	fmt.Fprintf(out, "Score : %d\n", result.Score.Int64)
+	fmt.Fprintf(out, "Status : %s\n", result.Status.String)
+	if result.Report != nil {
+		fmt.Fprintf(out, "Seed : %d\n", result.Report.Seed)
+	}
+	if len(result.Message.String) != 0 {
+		fmt.Fprint(out, result.Message.String)
+	}
+
+	return 0
+}
+
+// restartAgent serves the restart agent on a team's server
+func restartAgent(args []string) int {
+	fs := flag.NewFlagSet("agent", flag.ExitOnError)
+	addr := fs.String("addr", ":19000", "listen address of the agent")
+	command := fs.String("command", "", "shell command restarting the webapp and MySQL (e.g. \"systemctl restart mysql isuwitter\")")
+	token := fs.String("token", os.Getenv("YJ_ISUCON_BENCH_RESTART_TOKEN"), "shared secret expected from the benchmarker (env YJ_ISUCON_BENCH_RESTART_TOKEN)")
+	timeout := fs.Duration("timeout", time.Minute*2, "time limit of the command")
+
+	fs.Parse(args)
+
+	if *command == "" {
+		fmt.Fprintln(os.Stderr, "benchmarker agent: -command is required")
+		fs.Usage()
+		return 2
+	}
+
+	// the agent runs a shell command, it must never be open to anyone
+	if *token == "" {
+		fmt.Fprintln(os.Stderr, "benchmarker agent: -token is required")
+		fs.Usage()
+		return 2
+	}
+
+	l := logger.GetLogger()
+	defer l.Close()
+
+	l.Printf("agent : listening on %s", *addr)
+
+	if err := agent.NewServer(*command, *token, *timeout).Serve(*addr); err != nil {
+		l.Error(err)
+		return 1
+	}
+
+	return 0
+}
+
+// rescore prints the scores of archived reports under another rule set
+func rescore(args []string) int {
+	fs := flag.NewFlagSet("rescore", flag.ExitOnError)
+	rules := fs.String("rules", "", "scoring rule table (YAML), the built-in rules when empty")
+	tiers := fs.String("latency-tiers", "", "score by latency with tiers such as 100ms:4,1s:2,3s:1")
+
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: benchmarker rescore [-rules rules.yaml] [-latency-tiers tiers] report.json...")
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	rs := score.DefaultRules
+
+	if len(*rules) != 0 {
+		var err error
+		if rs, err = score.ReadRules(*rules); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+
+	if len(*tiers) != 0 {
+		t, err := score.ParseTiers(*tiers)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		rs = rs.WithTiers(t)
+	}
+
+	code := 0
+
+	for _, path := range fs.Args() {
+		r, err := report.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s : %v\n", path, err)
+			code = 1
+			continue
+		}
+
+		fmt.Printf("%s : %d (%s) -> %d (%s)\n", path, r.Score, r.RulesVersion, r.Rescore(rs), rs.Version)
+	}
+
+	return code
+}
+
+// shutdownContext returns a context cancelled by the first SIGINT or SIGTERM.
+// A second signal exits immediately.
+func shutdownContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sig := make(chan os.Signal, 2)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		l := logger.GetLogger()
+		l.Warnf("main : %s received, finishing running benches (send again to force exit)", <-sig)
+		cancel()
+		l.Fatalf("main : %s received, exiting", <-sig)
+	}()
+
+	return ctx
 }
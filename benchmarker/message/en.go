@@ -0,0 +1,62 @@
+package message
+
+var en = Catalog{
+	// session
+	"request.invalid_url":  "Invalid URL",
+	"request.failed":       "Request failed",
+	"request.status":       "Request failed.\n%s",
+	"request.post_timeout": "post request timeout",
+	"asset.fetch":          "Failed to fetch the static file : %s",
+
+	// checker
+	"init.nil_response":    "The initialize response is empty",
+	"init.failed":          "Initialization failed",
+	"init.timeout":         "Initialization took too long",
+	"asset.js":             "Invalid JavaScript file",
+	"asset.css":            "Invalid CSS file",
+	"asset.js_empty":       "The JavaScript file has no content",
+	"asset.css_empty":      "The CSS file has no content",
+	"guest.no_login_form":  "The login form is missing while logged out",
+	"guest.logout_button":  "The logout button is shown while logged out",
+	"guest.no_name":        "The user name is missing while logged out",
+	"guest.tweet_form":     "The tweet form is shown while logged out",
+	"mypage.title":         "The title is wrong",
+	"mypage.other_user":    "Tweets of another user are shown",
+	"login.no_error":       "The login error is missing",
+	"login.login_form":     "The login form is shown after login",
+	"login.no_logout":      "The logout button is missing after login",
+	"login.no_name":        "The user name is missing after login",
+	"login.no_tweet_form":  "The tweet form is missing after login",
+	"login.no_other_user":  "No other user is found on the timeline",
+	"tweet.no_time":        "The data-time attribute is missing",
+	"tweet.bad_time":       "The data-time attribute is malformed",
+	"paging.no_until":      "The until parameter is missing",
+	"paging.bad_until":     "The until parameter is malformed",
+	"paging.short":         "Too few tweets are shown",
+	"paging.order":         "Tweets are in the wrong order",
+	"selfpage.title":       "The page of the logged in user is wrong",
+	"user.missing":         "No matching user",
+	"unfollow.no_button":   "The unfollow button is missing",
+	"unfollow.still_shown": "The unfollowed user is still shown",
+	"follow.no_button":     "The follow button is missing",
+	"follow.not_shown":     "The followed user is not shown",
+	"tweet.missing":        "The posted tweet is missing",
+	"tweet.not_shown":      "The posted tweet is not shown",
+	"search.unmatched":     "Tweets that do not match the search are shown",
+	"verify.tweet":         "A posted tweet was lost",
+	"verify.follow":        "A follow was not applied",
+	"verify.unfollow":      "An unfollow was not applied",
+
+	// agent
+	"agent.not_ready": "The application did not start",
+
+	// disqualification reasons
+	"fail.initial":             "Initial check failed : %s",
+	"fail.asset":               "Static files have been modified : %s",
+	"fail.post_timeout":        "A POST request took longer than %s : %s",
+	"fail.consistency":         "Consistency errors exceeded %.1f%% (%d/%d)",
+	"fail.lost_writes":         "Writes were lost (%d/%d)",
+	"fail.lost_writes_restart": "Writes were lost after the restart (%d/%d)",
+	"fail.restart":             "Failed to restart the application : %s",
+	"fail.restart_not_ready":   "The application does not respond after the restart : %s",
+}
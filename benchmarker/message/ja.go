@@ -0,0 +1,62 @@
+package message
+
+var ja = Catalog{
+	// session
+	"request.invalid_url":  "不正なURLです",
+	"request.failed":       "リクエストに失敗しました",
+	"request.status":       "リクエストに失敗しました。\n%s",
+	"request.post_timeout": "POSTリクエストがタイムアウトしました",
+	"asset.fetch":          "静的ファイルの取得に失敗しました : %s",
+
+	// checker
+	"init.nil_response":    "初期化Responseがnilです",
+	"init.failed":          "初期化処理に失敗しました",
+	"init.timeout":         "初期化処理が長すぎます",
+	"asset.js":             "不正なJavaScriptファイルです",
+	"asset.css":            "不正なCSSファイルです",
+	"asset.js_empty":       "JavaScriptファイルの内容がありません",
+	"asset.css_empty":      "CSSファイルの内容がありません",
+	"guest.no_login_form":  "未ログイン時にログインフォームが見つかりません",
+	"guest.logout_button":  "未ログイン時にログアウトボタンが存在します",
+	"guest.no_name":        "非ログイン時にユーザー名が見つかりません",
+	"guest.tweet_form":     "未ログイン時にツイートフォームが存在します",
+	"mypage.title":         "タイトルが不適切です",
+	"mypage.other_user":    "異なるユーザーのツイートが含まれています",
+	"login.no_error":       "ログインエラーが見つかりません",
+	"login.login_form":     "ログイン時にログインフォームが存在します",
+	"login.no_logout":      "ログイン時にログアウトボタンが見つかりません",
+	"login.no_name":        "ログイン時にユーザー名が見つかりません",
+	"login.no_tweet_form":  "ログイン時にツイートフォームが見つかりません",
+	"login.no_other_user":  "タイムラインに他ユーザーが見つかりません",
+	"tweet.no_time":        "data-time属性が見つかりません",
+	"tweet.bad_time":       "data-time属性の形式が不適切です",
+	"paging.no_until":      "untilパラメータが見つかりません",
+	"paging.bad_until":     "untilパラメータの形式が不適切です",
+	"paging.short":         "表示されているツイートが足りません",
+	"paging.order":         "ツイートの並びが不適切です",
+	"selfpage.title":       "ログインユーザーのページが不適切です",
+	"user.missing":         "該当するユーザがいません",
+	"unfollow.no_button":   "アンフォローボタンがありません",
+	"unfollow.still_shown": "unfollowしたユーザが消えていません",
+	"follow.no_button":     "フォローボタンがありません",
+	"follow.not_shown":     "followしたユーザが表示されていません",
+	"tweet.missing":        "投稿したツイートが見つかりません",
+	"tweet.not_shown":      "投稿したツイートが表示されていません",
+	"search.unmatched":     "検索対象でないツイートが表示されています",
+	"verify.tweet":         "投稿したツイートが失われています",
+	"verify.follow":        "フォローが反映されていません",
+	"verify.unfollow":      "アンフォローが反映されていません",
+
+	// agent
+	"agent.not_ready": "アプリケーションが起動しませんでした",
+
+	// disqualification reasons
+	"fail.initial":             "初期チェックに失敗しました : %s",
+	"fail.asset":               "静的ファイルが改変されています : %s",
+	"fail.post_timeout":        "POSTリクエストが%sを超えました : %s",
+	"fail.consistency":         "整合性エラーが%.1f%%を超えました (%d/%d)",
+	"fail.lost_writes":         "書き込みが失われています (%d/%d)",
+	"fail.lost_writes_restart": "再起動後に書き込みが失われています (%d/%d)",
+	"fail.restart":             "アプリケーションの再起動に失敗しました : %s",
+	"fail.restart_not_ready":   "再起動後にアプリケーションが応答しません : %s",
+}
@@ -0,0 +1,40 @@
+package message
+
+import (
+	"fmt"
+
+	"github.com/yahoojapan/yisucon/benchmarker/config"
+)
+
+// Catalog maps the code of a message to its format in one language
+type Catalog map[string]string
+
+var catalogs = map[string]Catalog{
+	config.LocaleJA: ja,
+	config.LocaleEN: en,
+}
+
+// Supported reports whether there is a catalog for locale
+func Supported(locale string) bool {
+	_, ok := catalogs[locale]
+	return ok
+}
+
+// Get renders the message of code in locale with args. Unknown locales and
+// codes missing from a catalog fall back to Japanese, the language of the
+// contest, and unknown codes to the code itself.
+func Get(locale, code string, args ...interface{}) string {
+	format, ok := catalogs[locale][code]
+	if !ok {
+		format, ok = ja[code]
+	}
+	if !ok {
+		return code
+	}
+
+	if len(args) == 0 {
+		return format
+	}
+
+	return fmt.Sprintf(format, args...)
+}
@@ -0,0 +1,25 @@
+package message
+
+import "testing"
+
+// untranslated are the codes whose message is the same in every language
+var untranslated = map[string]bool{}
+
+func TestCatalogs(t *testing.T) {
+	for code, format := range ja {
+		e, ok := en[code]
+		if !ok {
+			t.Errorf("%s : missing from the en catalog", code)
+			continue
+		}
+		if e == format && !untranslated[code] {
+			t.Errorf("%s : the ja message is not translated : %q", code, format)
+		}
+	}
+
+	for code := range en {
+		if _, ok := ja[code]; !ok {
+			t.Errorf("%s : missing from the ja catalog", code)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "yisucon"
+	subsystem = "benchmarker"
+)
+
+var (
+	// QueuePolls counts queue polls by result (claimed, empty, error)
+	QueuePolls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "queue_polls_total",
+		Help:      "Number of queue polls by result.",
+	}, []string{"result"})
+
+	// QueueWait observes how long a job waited in the queue before it was claimed
+	QueueWait = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "queue_wait_seconds",
+		Help:      "Time a job waited in the queue before it was claimed.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+	})
+
+	// QueueExpired counts jobs whose lease expired by action (retried, aborted)
+	QueueExpired = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "queue_expired_total",
+		Help:      "Number of jobs whose lease expired by action.",
+	}, []string{"action"})
+
+	// Heartbeats counts lease renewals by result (ok, lost, error)
+	Heartbeats = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "heartbeats_total",
+		Help:      "Number of lease renewals by result.",
+	}, []string{"result"})
+
+	// RunsInProgress is the number of benches running now
+	RunsInProgress = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "runs_in_progress",
+		Help:      "Number of benchmark runs in progress.",
+	})
+
+	// RunDuration observes the wall time of a whole run including the portal hooks
+	RunDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "run_duration_seconds",
+		Help:      "Wall time of a benchmark run.",
+		Buckets:   prometheus.LinearBuckets(10, 10, 12),
+	})
+
+	// Scores observes the final score of each run per team
+	Scores = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "score",
+		Help:      "Final score of benchmark runs per team.",
+		Buckets:   prometheus.ExponentialBuckets(100, 2, 12),
+	}, []string{"team"})
+
+	// Actions counts checker actions by name and outcome (success, error, timeout)
+	Actions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "actions_total",
+		Help:      "Number of checker actions by name and outcome.",
+	}, []string{"action", "outcome"})
+
+	// ActionDuration observes the latency of checker actions
+	ActionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "action_duration_seconds",
+		Help:      "Latency of checker actions.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 14),
+	}, []string{"action", "method"})
+
+	// Disqualifications counts runs failed by a disqualification rule
+	Disqualifications = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "disqualifications_total",
+		Help:      "Number of runs failed by each disqualification rule.",
+	}, []string{"rule"})
+
+	// Connections counts responses by connection reuse and protocol, e.g. HTTP/2.0
+	Connections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "connections_total",
+		Help:      "Number of responses by connection reuse and protocol.",
+	}, []string{"reused", "proto"})
+
+	// Verifications counts writes checked after the bench by kind and result (ok, lost)
+	Verifications = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "verifications_total",
+		Help:      "Number of writes verified after the bench by kind and result.",
+	}, []string{"kind", "result"})
+
+	// Errors counts failed actions by the penalty category of score.CalcScore
+	Errors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "errors_total",
+		Help:      "Number of failed checker actions by category and failure kind.",
+	}, []string{"category", "kind"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		QueuePolls,
+		QueueWait,
+		QueueExpired,
+		Heartbeats,
+		RunsInProgress,
+		RunDuration,
+		Scores,
+		Actions,
+		ActionDuration,
+		Errors,
+		Verifications,
+		Connections,
+		Disqualifications,
+	)
+}
+
+// Serve exposes /metrics on addr. It blocks until the server fails.
+func Serve(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	return http.ListenAndServe(addr, mux)
+}
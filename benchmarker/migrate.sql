@@ -0,0 +1,26 @@
+-- Brings a database created by an older init.sql up to date without dropping
+-- the contest data. Run it once:
+--   mysql -u root < migrate.sql
+-- Fresh setups only need init.sql.
+
+USE `isucon` ;
+
+ALTER TABLE `isucon`.`team`
+  ADD COLUMN `locale` VARCHAR(8) NULL DEFAULT NULL AFTER `lang`;
+
+-- leases, retries and the reason of aborted jobs
+ALTER TABLE `isucon`.`queue`
+  ADD COLUMN `worker_id` VARCHAR(255) NULL DEFAULT NULL AFTER `status`,
+  ADD COLUMN `lease_expires` DATETIME NULL DEFAULT NULL AFTER `worker_id`,
+  ADD COLUMN `attempts` INT(10) UNSIGNED NOT NULL DEFAULT 0 AFTER `lease_expires`,
+  ADD COLUMN `reason` TEXT NULL DEFAULT NULL AFTER `attempts`;
+
+ALTER TABLE `isucon`.`score`
+  ADD COLUMN `status` VARCHAR(16) NOT NULL DEFAULT 'PASS' AFTER `message`,
+  ADD COLUMN `reason` TEXT NULL DEFAULT NULL AFTER `status`,
+  ADD COLUMN `config` TEXT NULL DEFAULT NULL AFTER `reason`,
+  ADD COLUMN `report` LONGTEXT NULL DEFAULT NULL AFTER `config`,
+  ADD COLUMN `transcript` LONGTEXT NULL DEFAULT NULL AFTER `report`,
+  ADD COLUMN `rules_version` VARCHAR(32) NULL DEFAULT NULL AFTER `transcript`;
+
+CREATE OR REPLACE VIEW `isucon`.`team_queue` AS select `t`.`id` AS `team_id`,`q`.`id` AS `queue_id`,`t`.`host` AS `host`,`q`.`status` AS `status`,`q`.`attempts` AS `attempts`,`t`.`locale` AS `locale`,`q`.`date` AS `date` from (`isucon`.`queue` `q` join `isucon`.`team` `t` on((`t`.`id` = `q`.`team_id`))) order by `q`.`date`;
@@ -1,6 +1,18 @@
 package model
 
-import "github.com/gocraft/dbr"
+import (
+	"github.com/gocraft/dbr"
+
+	"github.com/yahoojapan/yisucon/benchmarker/failure"
+	"github.com/yahoojapan/yisucon/benchmarker/report"
+)
+
+// Score status, the status of its report
+const (
+	StatusPass        = report.StatusPass
+	StatusInterrupted = report.StatusInterrupted
+	StatusFail        = report.StatusFail
+)
 
 type (
 	Team struct {
@@ -9,13 +21,19 @@ type (
 		Host  dbr.NullString `db:"host"`
 		Score dbr.NullInt64  `db:"best_score"`
 		Lang  dbr.NullString `db:"lang"`
+		// Locale is the language of the messages of the team's runs
+		Locale dbr.NullString `db:"locale"`
 	}
 
 	Queue struct {
-		ID     dbr.NullInt64 `db:"id"`
-		TeamID dbr.NullInt64 `db:"team_id"`
-		Status dbr.NullInt64 `db:"status"`
-		Date   dbr.NullTime  `db:"date"`
+		ID           dbr.NullInt64  `db:"id"`
+		TeamID       dbr.NullInt64  `db:"team_id"`
+		Status       dbr.NullInt64  `db:"status"`
+		WorkerID     dbr.NullString `db:"worker_id"`
+		LeaseExpires dbr.NullTime   `db:"lease_expires"`
+		Attempts     dbr.NullInt64  `db:"attempts"`
+		Reason       dbr.NullString `db:"reason"`
+		Date         dbr.NullTime   `db:"date"`
 	}
 
 	Score struct {
@@ -23,8 +41,16 @@ type (
 		QueueID dbr.NullInt64  `db:"queue_id"`
 		Score   dbr.NullInt64  `db:"score"`
 		Message dbr.NullString `db:"message"`
+		Status  dbr.NullString `db:"status"`
+		Reason  dbr.NullString `db:"reason"`
+		Config  dbr.NullString `db:"config"`
 		Date    dbr.NullTime   `db:"date"`
 		Errors  []*Error
+		Report  *report.Report
+		// RulesVersion is the version of the scoring rules of Score
+		RulesVersion dbr.NullString `db:"rules_version"`
+		// Locale is the language Message is rendered in
+		Locale string
 	}
 
 	User struct {
@@ -35,11 +61,13 @@ type (
 	}
 
 	TeamQueue struct {
-		TeamID  dbr.NullInt64  `db:"team_id" json:"team_id"`
-		QueueID dbr.NullInt64  `db:"queue_id" json:"queue_id"`
-		Host    dbr.NullString `db:"host" json:"host"`
-		Status  dbr.NullInt64  `db:"status" json:"status"`
-		Date    dbr.NullTime   `db:"date" json:"date"`
+		TeamID   dbr.NullInt64  `db:"team_id" json:"team_id"`
+		QueueID  dbr.NullInt64  `db:"queue_id" json:"queue_id"`
+		Host     dbr.NullString `db:"host" json:"host"`
+		Status   dbr.NullInt64  `db:"status" json:"status"`
+		Attempts dbr.NullInt64  `db:"attempts" json:"attempts"`
+		Locale   dbr.NullString `db:"locale" json:"locale"`
+		Date     dbr.NullTime   `db:"date" json:"date"`
 	}
 
 	Account struct {
@@ -59,9 +87,12 @@ type (
 )
 
 func (s *Score) CreateErrMessage() {
+	if len(s.Reason.String) != 0 {
+		s.Message.String += s.Status.String + " : " + s.Reason.String + "\n"
+	}
 	enc := map[string]bool{}
 	for _, errs := range s.Errors {
-		err := errs.Error.Error()
+		err := failure.Localize(errs.Error, s.Locale)
 		if len(err) != 0 && err != "\n" && err != " " && !enc[err] {
 			enc[err] = true
 			s.Message.String += err + "\n"
@@ -0,0 +1,77 @@
+package processor
+
+import (
+	"strings"
+
+	"github.com/yahoojapan/yisucon/benchmarker/config"
+	"github.com/yahoojapan/yisucon/benchmarker/failure"
+	"github.com/yahoojapan/yisucon/benchmarker/message"
+	"github.com/yahoojapan/yisucon/benchmarker/metrics"
+	"github.com/yahoojapan/yisucon/benchmarker/score"
+)
+
+// judge applies the disqualification rules to each action result
+type judge struct {
+	locale  string
+	actions map[string]bool
+	checked int
+	failed  int
+}
+
+func newJudge(locale string) *judge {
+	actions := make(map[string]bool)
+
+	for _, name := range strings.Split(config.FailConsistencyActions, ",") {
+		if name = strings.TrimSpace(name); len(name) != 0 {
+			actions[name] = true
+		}
+	}
+
+	return &judge{
+		locale:  locale,
+		actions: actions,
+	}
+}
+
+// observe returns why s disqualifies the run, or an empty string
+func (j *judge) observe(s score.Score) string {
+	rule, reason := j.check(s)
+
+	if len(rule) != 0 {
+		metrics.Disqualifications.WithLabelValues(rule).Inc()
+	}
+
+	return reason
+}
+
+func (j *judge) check(s score.Score) (rule, reason string) {
+	if config.FailOnAssetMismatch && failure.KindOf(s.Error) == failure.AssetHashMismatch {
+		return "asset", message.Get(j.locale, "fail.asset", failure.Localize(s.Error, j.locale))
+	}
+
+	if config.FailOnPostTimeout && s.Category == score.CategoryPostTimeout {
+		return "post_timeout", message.Get(j.locale, "fail.post_timeout", config.RequestTimeout, s.Name)
+	}
+
+	if config.FailConsistencyRate == 0 || !j.actions[s.Name] {
+		return "", ""
+	}
+
+	j.checked++
+
+	// a content error comes with a response the app considered successful
+	switch failure.KindOf(s.Error) {
+	case failure.ContentMismatch, failure.MissingElement:
+		j.failed++
+	}
+
+	if j.checked < config.FailConsistencyMin {
+		return "", ""
+	}
+
+	if rate := float64(j.failed) / float64(j.checked); rate > config.FailConsistencyRate {
+		return "consistency", message.Get(j.locale, "fail.consistency", config.FailConsistencyRate*100, j.failed, j.checked)
+	}
+
+	return "", ""
+}
@@ -0,0 +1,148 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gocraft/dbr"
+
+	"github.com/yahoojapan/yisucon/benchmarker/config"
+	"github.com/yahoojapan/yisucon/benchmarker/failure"
+	"github.com/yahoojapan/yisucon/benchmarker/logger"
+	"github.com/yahoojapan/yisucon/benchmarker/message"
+	"github.com/yahoojapan/yisucon/benchmarker/model"
+	"github.com/yahoojapan/yisucon/benchmarker/score"
+	"github.com/yahoojapan/yisucon/benchmarker/session"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "processor")
+	if err != nil {
+		panic(err)
+	}
+
+	config.LogFilePath = filepath.Join(dir, "benchmarker.log")
+	config.LogLevel = "error"
+	config.RequestTimeout = time.Millisecond * 100
+
+	code := m.Run()
+
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestPostTimeout(t *testing.T) {
+	// a target slower than the request timeout
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer target.Close()
+
+	tests := []struct {
+		name      string
+		method    string
+		cancelled bool
+		want      string
+	}{
+		{"slow POST", http.MethodPost, false, message.Get(config.LocaleJA, "fail.post_timeout", config.RequestTimeout, "PostTweet")},
+		{"slow GET", http.MethodGet, false, ""},
+		{"POST cut by the end of the run", http.MethodPost, true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			sess := session.NewSession(ctx, target.URL)
+			defer sess.Close()
+
+			if tt.cancelled {
+				time.AfterFunc(config.RequestTimeout/2, cancel)
+			}
+
+			sc := score.CalcScore(tt.method, "PostTweet", func() (int, error) {
+				_, err := sess.SendSimpleRequest(tt.method, fmt.Sprintf("http://%s/", sess.Host), nil)
+				return 1, err
+			})
+
+			if sc.Error == nil {
+				t.Fatal("a request slower than the timeout succeeded")
+			}
+
+			p := &Processor{log: logger.GetLogger()}
+			s := &model.Score{Status: dbr.NewNullString(model.StatusPass)}
+
+			reason := newJudge(config.LocaleJA).observe(sc)
+			if reason != tt.want {
+				t.Fatalf("observe() = %q, want %q", reason, tt.want)
+			}
+			if len(reason) == 0 {
+				return
+			}
+
+			p.fail(s, reason)
+
+			if s.Status.String != model.StatusFail || s.Reason.String != tt.want {
+				t.Errorf("got %s %q, want %s %q", s.Status.String, s.Reason.String, model.StatusFail, tt.want)
+			}
+		})
+	}
+}
+
+func TestJudge(t *testing.T) {
+	defer func(min int, rate float64) {
+		config.FailConsistencyMin, config.FailConsistencyRate = min, rate
+	}(config.FailConsistencyMin, config.FailConsistencyRate)
+
+	config.FailConsistencyMin = 4
+	config.FailConsistencyRate = 0.3
+
+	var (
+		ok       = score.Score{Name: "TweetCheck"}
+		mismatch = score.Score{Name: "TweetCheck", Error: failure.New(failure.ContentMismatch, "", "request.failed")}
+		status   = score.Score{Name: "TweetCheck", Error: failure.Status("/", http.StatusInternalServerError, "request.status", "500")}
+		other    = score.Score{Name: "LoginCheck", Error: failure.New(failure.ContentMismatch, "", "request.failed")}
+		asset    = score.Score{Name: "StaticCheck", Error: failure.Asset("/js/script.js", "asset.js")}
+	)
+
+	tests := []struct {
+		name   string
+		scores []score.Score
+		// want is the rule the last score breaks
+		want string
+	}{
+		{"asset", []score.Score{ok, asset}, "asset"},
+		{"consistency", []score.Score{ok, mismatch, ok, mismatch}, "consistency"},
+		{"under the minimum", []score.Score{mismatch, mismatch, mismatch}, ""},
+		{"under the rate", []score.Score{ok, ok, ok, mismatch}, ""},
+		{"status errors", []score.Score{ok, status, status, status}, ""},
+		{"other actions", []score.Score{other, other, other, other}, ""},
+	}
+
+	for _, tt := range tests {
+		j := newJudge(config.LocaleJA)
+
+		var rule string
+		for i, s := range tt.scores {
+			rule, _ = j.check(s)
+			if len(rule) != 0 && i != len(tt.scores)-1 {
+				t.Errorf("%s : score %d broke %s", tt.name, i, rule)
+			}
+		}
+
+		if rule != tt.want {
+			t.Errorf("%s : rule = %q, want %q", tt.name, rule, tt.want)
+		}
+	}
+}
@@ -3,19 +3,31 @@ package processor
 import (
 	"container/ring"
 	"context"
+	"math/rand"
 	"sync"
 	"time"
 
+	"github.com/gocraft/dbr"
+
 	"github.com/yahoojapan/yisucon/benchmarker/checker"
 	"github.com/yahoojapan/yisucon/benchmarker/config"
+	"github.com/yahoojapan/yisucon/benchmarker/failure"
 	"github.com/yahoojapan/yisucon/benchmarker/logger"
+	"github.com/yahoojapan/yisucon/benchmarker/message"
+	"github.com/yahoojapan/yisucon/benchmarker/metrics"
 	"github.com/yahoojapan/yisucon/benchmarker/model"
+	"github.com/yahoojapan/yisucon/benchmarker/report"
 	"github.com/yahoojapan/yisucon/benchmarker/score"
+	"github.com/yahoojapan/yisucon/benchmarker/stats"
 	"github.com/yahoojapan/yisucon/benchmarker/worker"
 )
 
 type Processor struct {
+	// w is the worker of the last run, runs counts them. Both are guarded by
+	// mu since runs start concurrently.
 	w      *ring.Ring
+	runs   int64
+	mu     *sync.Mutex
 	wg     *sync.WaitGroup //Process WaitGroup
 	cwg    *sync.WaitGroup //Broadcast WaitGroup
 	cond   *sync.Cond      //Broadcast Condition
@@ -24,59 +36,127 @@ type Processor struct {
 	result chan score.Score
 	done   chan struct{}
 	log    *logger.Logger
+	report *report.Report
+	rec    *stats.Recorder
+	seed   int64
+	// locale is the language of the failure reasons and the report
+	locale string
+	// journal collects the writes of every worker for Verify, kept are
+	// the ones Verify found and Restart checks again
+	journal *checker.Journal
+	kept    []checker.Write
 }
 
-func NewProcessor(host string) (*Processor, error) {
-	w, err := worker.NewWorkers(host)
+// NewProcessor prepares a run against host whose messages are in locale.
+// Its entries go to l.
+func NewProcessor(host, locale string, l *logger.Logger) (*Processor, error) {
+	rec := stats.NewRecorder()
+
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	w, err := worker.NewWorkers(host, rec, seed, l)
 
 	if err != nil {
 		return nil, err
 	}
 
+	journal := checker.NewJournal()
+
+	w.Do(func(v interface{}) {
+		v.(*worker.Worker).Journal = journal
+	})
+
 	return &Processor{
 		w:      w,
+		mu:     new(sync.Mutex),
 		wg:     new(sync.WaitGroup),
 		cwg:    new(sync.WaitGroup),
 		cond:   sync.NewCond(new(sync.Mutex)),
-		result: make(chan score.Score, config.MaxWorkerCount*config.MaxCheckers),
-		done:   make(chan struct{}, config.MaxWorkerCount),
-		log:    logger.GetLogger(),
+		result: make(chan score.Score, maxWorkers()*config.MaxCheckers),
+		done:   make(chan struct{}, maxWorkers()),
+		log:    l,
+		report: report.NewReport(host, seed, locale),
+		rec:    rec,
+		seed:   seed,
+		locale: locale,
+
+		journal: journal,
 	}, nil
 }
 
+// maxWorkers is the largest number of concurrent workers of a run
+func maxWorkers() int {
+	if config.LoadMode == config.LoadModeRamp && config.RampMaxWorkers > config.MaxWorkerCount {
+		return config.RampMaxWorkers
+	}
+	return config.MaxWorkerCount
+}
+
 func (p *Processor) work() {
 	defer p.wg.Done()
+	p.mu.Lock()
 	p.w = p.w.Next()
-	err := p.w.Value.(*worker.Worker).Run(p.ctx, p.result)
+	w := p.w.Value.(*worker.Worker)
+	p.runs++
+	run := p.runs
+	p.mu.Unlock()
+	err := w.Run(p.ctx, p.result, run)
 	if err != nil {
-		p.log.Println(err)
+		w.Log.Debug(err)
 		return
 	}
 	p.done <- struct{}{}
 }
 
-func (p *Processor) Run(dur time.Duration) *model.Score {
+// Run benchmarks for dur. Cancelling parent stops the run early and marks the
+// partial score as interrupted.
+func (p *Processor) Run(parent context.Context, dur time.Duration) *model.Score {
 
 	defer close(p.done)
 	defer close(p.result)
 	defer p.wg.Wait()
 
-	s := new(model.Score)
+	s := &model.Score{
+		Status: dbr.NewNullString(model.StatusPass),
+		Report: p.report,
+		Locale: p.locale,
+
+		RulesVersion: dbr.NewNullString(p.report.RulesVersion),
+	}
+
+	defer func() {
+		if parent.Err() != nil && s.Status.String == model.StatusPass {
+			s.Status = dbr.NewNullString(model.StatusInterrupted)
+		}
+		p.report.Finish(s.Score.Int64, s.Status.String, s.Reason.String, p.rec)
+	}()
+
+	p.log.Printf("processor : seed %d\n", p.seed)
 
 	var err error
 
-	s.Score.Int64, err = p.initialProcess()
+	s.Score.Int64, err = p.initialProcess(parent)
 
 	if err != nil {
-		p.log.Println("processor : initialProcess error")
+		p.log.Errorf("processor : initialProcess error : %v", err)
 		s.Errors = append(s.Errors, &model.Error{
 			Error:   err,
 			Message: err.Error(),
 		})
+		if parent.Err() == nil {
+			p.fail(s, message.Get(p.locale, "fail.initial", failure.Localize(err, p.locale)))
+		}
+		return s
+	}
+
+	if parent.Err() != nil {
 		return s
 	}
 
-	for i := 0; i < cap(p.done); i++ {
+	for i := 0; i < config.MaxWorkerCount; i++ {
 		p.wg.Add(1)
 		p.cwg.Add(1)
 		go func() {
@@ -90,46 +170,150 @@ func (p *Processor) Run(dur time.Duration) *model.Score {
 
 	p.cwg.Wait()
 
-	//Start timer
-	p.ctx, p.cancel = context.WithTimeout(context.Background(), dur)
+	//Start timer, under the lock the waiting workers wake up with
+	p.cond.L.Lock()
+	p.ctx, p.cancel = context.WithTimeout(parent, dur)
+	p.cond.L.Unlock()
 
 	start := time.Now()
 
+	p.rec.Start()
+
 	defer p.cancel()
 
 	p.cond.Broadcast()
 
+	var (
+		rp     *ramp
+		warmup <-chan time.Time
+		tick   <-chan time.Time
+		jd     = newJudge(p.locale)
+	)
+
+	if config.LoadMode == config.LoadModeRamp {
+		rp = newRamp(start)
+		p.report.Load = rp.load
+		warmup = time.After(config.RampWarmup)
+	}
+
 	for {
 		select {
 		case <-p.ctx.Done():
-			p.log.Println(time.Since(start))
+			p.log.Printf("processor : finished in %s", time.Since(start))
+			// the requests of Verify and Restart are out of the bench
+			p.rec.Stop()
+			if rp != nil {
+				bonus := rp.bonus()
+				s.Score.Int64 += bonus
+				p.report.Adjust(bonus)
+				p.log.Printf("processor : sustained %d workers\n", rp.load.Sustained)
+			}
+			// writes still in flight are acknowledged before they are verified
+			p.wg.Wait()
+			if parent.Err() == nil {
+				p.Verify(parent, s)
+			}
+			if parent.Err() == nil && s.Status.String == model.StatusPass {
+				p.Restart(parent, s)
+			}
 			if s.Score.Int64 < 0 {
 				s.Score.Int64 = 0
 			}
 			return s
+		case <-warmup:
+			ticker := time.NewTicker(config.RampInterval)
+			defer ticker.Stop()
+			tick = ticker.C
+			rp.reset()
+		case <-tick:
+			for i := rp.next(); i > 0; i-- {
+				p.wg.Add(1)
+				go p.work()
+			}
 		case <-p.done:
 			p.wg.Add(1)
 			go p.work()
 		case result := <-p.result:
-			go func(res *score.Score) {
-				s.Score.Int64 += int64(res.Score)
-				if res.Error != nil {
-					s.Errors = append(s.Errors, &model.Error{
-						Error: res.Error,
-					})
+			p.report.Add(result)
+			if len(result.Name) != 0 {
+				p.trace(result)
+				observe(result)
+				p.rec.Action(result.Score, result.Error != nil)
+				if rp != nil {
+					rp.observe(result.Error != nil)
 				}
-			}(&result)
+				if reason := jd.observe(result); len(reason) != 0 {
+					if result.Error != nil {
+						s.Errors = append(s.Errors, &model.Error{
+							Error: result.Error,
+						})
+					}
+					p.fail(s, reason)
+					return s
+				}
+			}
+			s.Score.Int64 += int64(result.Score)
+			if result.Error != nil {
+				s.Errors = append(s.Errors, &model.Error{
+					Error: result.Error,
+				})
+			}
 		}
 	}
 }
 
-func (p *Processor) initialProcess() (s int64, err error) {
+// fail disqualifies the run: the score drops to 0 and the workers stop
+func (p *Processor) fail(s *model.Score, reason string) {
+	p.log.Warnf("processor : FAIL : %s", reason)
+
+	s.Score.Int64 = 0
+	s.Status = dbr.NewNullString(model.StatusFail)
+	s.Reason = dbr.NewNullString(reason)
+
+	if p.cancel != nil {
+		p.cancel()
+	}
+}
+
+// trace logs an action result, failures at warn level and the rest at debug
+func (p *Processor) trace(s score.Score) {
+	l := p.log.With("action", s.Name).With("latency", s.Elapsed)
+
+	if s.Error != nil {
+		l.With("category", s.Category).With("kind", failure.KindOf(s.Error)).Warn(s.Error)
+		return
+	}
+
+	l.Debug("ok")
+}
+
+// observe exports an action result to the metrics endpoint
+func observe(s score.Score) {
+	outcome := "success"
+	switch {
+	case s.Timeout:
+		outcome = "timeout"
+	case s.Error != nil:
+		outcome = "error"
+	}
+
+	metrics.Actions.WithLabelValues(s.Name, outcome).Inc()
+	metrics.ActionDuration.WithLabelValues(s.Name, s.Method).Observe(s.Elapsed.Seconds())
+
+	if s.Error != nil {
+		metrics.Errors.WithLabelValues(s.Category, string(failure.KindOf(s.Error))).Inc()
+	}
+}
+
+func (p *Processor) initialProcess(parent context.Context) (s int64, err error) {
 	w := p.w.Value.(*worker.Worker)
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(parent)
 	defer cancel()
 
-	c := checker.NewChecker(ctx, w.Host, w.Account)
+	c := checker.NewChecker(ctx, w.Host, w.Account, p.rec, rand.New(rand.NewSource(p.seed)))
+	c.Logger = w.Log
+	c.Journal = p.journal
 	defer c.Close()
 
 	scenario := checker.NewInitScenario(c)
@@ -151,6 +335,11 @@ func (p *Processor) initialProcess() (s int64, err error) {
 		case <-ctx.Done():
 			return s, nil
 		case sc := <-res:
+			p.report.Add(sc)
+			if len(sc.Name) != 0 {
+				p.trace(sc)
+				observe(sc)
+			}
 			if sc.Error != nil {
 				return 0, sc.Error
 			}
@@ -162,7 +351,7 @@ func (p *Processor) initialProcess() (s int64, err error) {
 
 			wg.Add(1)
 			go func(action *checker.Action) {
-				res <- score.CalcScore(action.Method, action.Name, action.Action)
+				res <- c.Run(action)
 				wg.Done()
 			}(scenario.Pop())
 		}
@@ -0,0 +1,97 @@
+package processor
+
+import (
+	"time"
+
+	"github.com/yahoojapan/yisucon/benchmarker/config"
+	"github.com/yahoojapan/yisucon/benchmarker/report"
+)
+
+// ramp adds workers step by step while the error rate of the last
+// interval stays under config.RampErrorRate
+type ramp struct {
+	start   time.Time
+	stopped bool
+	actions int
+	errors  int
+	load    *report.Load
+}
+
+func newRamp(start time.Time) *ramp {
+	return &ramp{
+		start: start,
+		load: &report.Load{
+			Mode:    config.LoadModeRamp,
+			Initial: config.MaxWorkerCount,
+			Peak:    config.MaxWorkerCount,
+		},
+	}
+}
+
+// observe counts the result of one action in the current interval
+func (r *ramp) observe(failed bool) {
+	r.actions++
+	if failed {
+		r.errors++
+	}
+}
+
+// reset starts a new interval
+func (r *ramp) reset() {
+	r.actions = 0
+	r.errors = 0
+}
+
+// next judges the interval that just ended and returns the number of
+// workers to add
+func (r *ramp) next() int {
+	defer r.reset()
+
+	rate := 0.0
+	if r.actions != 0 {
+		rate = float64(r.errors) / float64(r.actions)
+	}
+
+	passed := rate < config.RampErrorRate
+
+	r.load.Steps = append(r.load.Steps, report.LoadStep{
+		Second:    int(time.Since(r.start) / time.Second),
+		Workers:   r.load.Peak,
+		ErrorRate: rate,
+		Passed:    passed,
+	})
+
+	if r.stopped {
+		return 0
+	}
+
+	if !passed {
+		// hold the current level until the end of the bench
+		r.stopped = true
+		return 0
+	}
+
+	r.load.Sustained = r.load.Peak
+
+	add := config.RampStep
+	if r.load.Peak+add > config.RampMaxWorkers {
+		add = config.RampMaxWorkers - r.load.Peak
+	}
+
+	if add <= 0 {
+		r.stopped = true
+		return 0
+	}
+
+	r.load.Peak += add
+
+	return add
+}
+
+// bonus is the score reward for the concurrency sustained above the initial level
+func (r *ramp) bonus() int64 {
+	if r.load.Sustained <= r.load.Initial {
+		return 0
+	}
+	return int64(r.load.Sustained-r.load.Initial) * int64(config.RampWorkerBonus)
+}
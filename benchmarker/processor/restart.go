@@ -0,0 +1,66 @@
+package processor
+
+import (
+	"context"
+	"time"
+
+	"github.com/yahoojapan/yisucon/benchmarker/agent"
+	"github.com/yahoojapan/yisucon/benchmarker/config"
+	"github.com/yahoojapan/yisucon/benchmarker/failure"
+	"github.com/yahoojapan/yisucon/benchmarker/message"
+	"github.com/yahoojapan/yisucon/benchmarker/metrics"
+	"github.com/yahoojapan/yisucon/benchmarker/model"
+	"github.com/yahoojapan/yisucon/benchmarker/report"
+)
+
+// readyInterval is the period of the readiness probe after a restart
+const readyInterval = time.Second
+
+// Restart asks the team's agent to restart the app, waits until it answers
+// again and verifies the writes Verify found once more. An app that keeps its
+// data only in memory fails here.
+func (p *Processor) Restart(parent context.Context, s *model.Score) {
+	if config.RestartAgentPort == 0 {
+		return
+	}
+
+	r := new(report.Restart)
+	p.report.Restart = r
+
+	ctx, cancel := context.WithTimeout(parent, config.RestartTimeout)
+	defer cancel()
+
+	start := time.Now()
+
+	defer func() {
+		r.Elapsed = time.Since(start).String()
+	}()
+
+	p.log.Printf("processor : restarting the app")
+
+	if err := agent.Restart(ctx, p.host(), config.RestartAgentPort, config.RestartToken); err != nil {
+		if parent.Err() != nil {
+			return
+		}
+		r.Error = failure.Localize(err, p.locale)
+		metrics.Disqualifications.WithLabelValues("restart").Inc()
+		p.fail(s, message.Get(p.locale, "fail.restart", r.Error))
+		return
+	}
+
+	if err := agent.WaitReady(ctx, p.host(), readyInterval); err != nil {
+		if parent.Err() != nil {
+			return
+		}
+		r.Error = failure.Localize(err, p.locale)
+		metrics.Disqualifications.WithLabelValues("restart").Inc()
+		p.fail(s, message.Get(p.locale, "fail.restart_not_ready", config.RestartTimeout))
+		return
+	}
+
+	p.log.Printf("processor : app restarted in %s", time.Since(start))
+
+	if len(p.kept) != 0 {
+		r.Verification, _ = p.verify(parent, s, p.kept, "fail.lost_writes_restart")
+	}
+}
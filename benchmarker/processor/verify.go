@@ -0,0 +1,122 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"github.com/yahoojapan/yisucon/benchmarker/checker"
+	"github.com/yahoojapan/yisucon/benchmarker/config"
+	"github.com/yahoojapan/yisucon/benchmarker/failure"
+	"github.com/yahoojapan/yisucon/benchmarker/message"
+	"github.com/yahoojapan/yisucon/benchmarker/metrics"
+	"github.com/yahoojapan/yisucon/benchmarker/model"
+	"github.com/yahoojapan/yisucon/benchmarker/report"
+	"github.com/yahoojapan/yisucon/benchmarker/worker"
+)
+
+// verifyParallel bounds the writes verified at the same time
+const verifyParallel = 4
+
+// Verify checks a sample of the writes acknowledged during the bench with
+// fresh sessions. Lost writes cost config.VerifyPenalty each and fail the run
+// past config.VerifyFailRate.
+func (p *Processor) Verify(parent context.Context, s *model.Score) {
+	if config.VerifySample == 0 || p.journal.Len() == 0 {
+		return
+	}
+
+	// the draw follows the seed so that a replay verifies the same writes
+	writes := p.journal.Sample(config.VerifySample, rand.New(rand.NewSource(p.seed)))
+
+	p.report.Verification, p.kept = p.verify(parent, s, writes, "fail.lost_writes")
+}
+
+// verify checks writes and returns the result and the writes found. reason
+// is the message code of the failure reason.
+func (p *Processor) verify(parent context.Context, s *model.Score, writes []checker.Write, reason string) (*report.Verification, []checker.Write) {
+	ctx, cancel := context.WithTimeout(parent, config.VerifyTimeout)
+	defer cancel()
+
+	v := &report.Verification{
+		Writes: p.journal.Len(),
+	}
+
+	var kept []checker.Write
+
+	host := p.host()
+
+	l := new(sync.Mutex)
+	wg := new(sync.WaitGroup)
+	sem := make(chan struct{}, verifyParallel)
+
+	for i, w := range writes {
+		select {
+		case <-ctx.Done():
+		case sem <- struct{}{}:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, w checker.Write) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			c := checker.NewChecker(ctx, host, w.Account, nil, rand.New(rand.NewSource(p.seed+int64(i))))
+			c.Logger = p.log
+			defer c.Close()
+
+			err := c.Verify(w)
+
+			// a verification cut by the time limit proves nothing either way
+			if err != nil && ctx.Err() != nil {
+				return
+			}
+
+			defer l.Unlock()
+			l.Lock()
+
+			v.Sampled++
+
+			if err == nil {
+				metrics.Verifications.WithLabelValues(w.Kind, "ok").Inc()
+				kept = append(kept, w)
+				return
+			}
+
+			metrics.Verifications.WithLabelValues(w.Kind, "lost").Inc()
+			p.log.With("kind", w.Kind).With("account", w.Account.Name).Warn(err)
+
+			v.Lost++
+			v.Errors = append(v.Errors, fmt.Sprintf("%s (%s) : %s", w.Account.Name, w.Kind, failure.Localize(err, p.locale)))
+			s.Errors = append(s.Errors, &model.Error{
+				Error: err,
+			})
+		}(i, w)
+	}
+
+	wg.Wait()
+
+	v.Penalty = int64(v.Lost * config.VerifyPenalty)
+	s.Score.Int64 -= v.Penalty
+	p.report.Adjust(-v.Penalty)
+
+	p.log.Printf("processor : verified %d writes, %d lost", v.Sampled, v.Lost)
+
+	if v.Sampled != 0 && float64(v.Lost)/float64(v.Sampled) > config.VerifyFailRate {
+		metrics.Disqualifications.WithLabelValues("lost_writes").Inc()
+		p.fail(s, message.Get(p.locale, reason, v.Lost, v.Sampled))
+	}
+
+	return v, kept
+}
+
+// host is the target of the run
+func (p *Processor) host() string {
+	return p.w.Value.(*worker.Worker).Host
+}
@@ -0,0 +1,370 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/yahoojapan/yisucon/benchmarker/config"
+	"github.com/yahoojapan/yisucon/benchmarker/failure"
+	"github.com/yahoojapan/yisucon/benchmarker/score"
+	"github.com/yahoojapan/yisucon/benchmarker/stats"
+)
+
+// Status of a run
+const (
+	// StatusPass is a run that lasted the whole bench time
+	StatusPass = "PASS"
+	// StatusInterrupted is a partial run cut short by a benchmarker shutdown
+	StatusInterrupted = "INTERRUPTED"
+	// StatusFail is a run stopped by a disqualification rule with score 0
+	StatusFail = "FAIL"
+)
+
+// Report is the machine-readable breakdown of a benchmark run
+type Report struct {
+	Target   string             `json:"target"`
+	TeamID   int64              `json:"team_id,omitempty"`
+	QueueID  int64              `json:"queue_id,omitempty"`
+	Score    int64              `json:"score"`
+	Status   string             `json:"status"`
+	Reason   string             `json:"reason,omitempty"`
+	Seed     int64              `json:"seed"`
+	Started  time.Time          `json:"started_at"`
+	Duration string             `json:"duration"`
+	Actions  map[string]*Action `json:"actions"`
+
+	// RulesVersion is the score.RuleSet the action results were scored with
+	RulesVersion string `json:"rules_version"`
+	// Adjustment is the part of Score not from action results, such as the
+	// ramp bonus and the penalty of lost writes
+	Adjustment int64 `json:"adjustment"`
+	// Tiers counts the successes per latency tier in the latency scoring mode
+	Tiers map[string]int64 `json:"tiers,omitempty"`
+	// Locale is the language of Reason and the error messages
+	Locale string `json:"locale"`
+
+	Endpoints map[string]*stats.Endpoint `json:"endpoints"`
+	Timeline  []stats.Bucket             `json:"timeline"`
+	Conns     stats.Connections          `json:"connections"`
+	Load      *Load                      `json:"load,omitempty"`
+
+	Verification *Verification `json:"verification,omitempty"`
+	Restart      *Restart      `json:"restart,omitempty"`
+
+	// Tallies are the raw action results a rescore applies new rules to
+	Tallies []score.Tally `json:"tallies"`
+
+	// Transcript lists the first failures of the run, TranscriptDropped
+	// counts those that did not fit
+	Transcript        []Entry `json:"transcript"`
+	TranscriptDropped int     `json:"transcript_dropped,omitempty"`
+
+	l       *sync.Mutex
+	limit   int
+	tallies score.Tallies
+}
+
+// Entry is a failed action and the request that failed it
+type Entry struct {
+	At      float64 `json:"at"`
+	Action  string  `json:"action"`
+	Method  string  `json:"method"`
+	URL     string  `json:"url,omitempty"`
+	Status  int     `json:"status,omitempty"`
+	Snippet string  `json:"snippet,omitempty"`
+	Elapsed float64 `json:"elapsed_ms"`
+	Error   string  `json:"error"`
+
+	// Kind classifies Error, Selector locates the element that failed
+	Kind     failure.Kind `json:"kind"`
+	Selector string       `json:"selector,omitempty"`
+}
+
+// Action aggregates the results of one checker.Action name
+type Action struct {
+	Method  string         `json:"method"`
+	Success int            `json:"success"`
+	Failure int            `json:"failure"`
+	Timeout int            `json:"timeout"`
+	Score   int64          `json:"score"`
+	Latency stats.Summary  `json:"latency"`
+	Errors  map[string]int `json:"errors,omitempty"`
+	// Kinds counts the failures per failure kind
+	Kinds map[failure.Kind]int `json:"kinds,omitempty"`
+	// Tiers counts the successes per latency tier in the latency scoring mode
+	Tiers map[string]int64 `json:"tiers,omitempty"`
+
+	hist *stats.Histogram
+}
+
+// Load describes the worker ramp of a run in ramp mode
+type Load struct {
+	Mode      string     `json:"mode"`
+	Initial   int        `json:"initial_workers"`
+	Peak      int        `json:"peak_workers"`
+	Sustained int        `json:"sustained_workers"`
+	Steps     []LoadStep `json:"steps"`
+}
+
+// Verification is the result of checking the writes of a run after the bench
+type Verification struct {
+	Writes  int      `json:"writes"`
+	Sampled int      `json:"sampled"`
+	Lost    int      `json:"lost"`
+	Penalty int64    `json:"penalty"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// Restart is the result of restarting the app after the bench and checking
+// the verified writes again
+type Restart struct {
+	Elapsed      string        `json:"elapsed"`
+	Error        string        `json:"error,omitempty"`
+	Verification *Verification `json:"verification,omitempty"`
+}
+
+// LoadStep is the judgement of one ramp interval
+type LoadStep struct {
+	Second    int     `json:"second"`
+	Workers   int     `json:"workers"`
+	ErrorRate float64 `json:"error_rate"`
+	Passed    bool    `json:"passed"`
+}
+
+func NewReport(target string, seed int64, locale string) *Report {
+	return &Report{
+		Target:  target,
+		Seed:    seed,
+		Started: time.Now(),
+		Actions: make(map[string]*Action),
+		l:       new(sync.Mutex),
+		limit:   config.TranscriptSize,
+		tallies: make(score.Tallies),
+
+		RulesVersion: score.Rules().Version,
+		Locale:       locale,
+	}
+}
+
+// Add records a single checker result
+func (r *Report) Add(s score.Score) {
+	if len(s.Name) == 0 {
+		return
+	}
+
+	defer r.l.Unlock()
+	r.l.Lock()
+
+	a, ok := r.Actions[s.Name]
+	if !ok {
+		a = &Action{
+			Method: s.Method,
+			Errors: make(map[string]int),
+			Kinds:  make(map[failure.Kind]int),
+			hist:   stats.NewHistogram(),
+		}
+		r.Actions[s.Name] = a
+	}
+
+	switch {
+	case s.Timeout:
+		a.Timeout++
+	case s.Error != nil:
+		a.Failure++
+	default:
+		a.Success++
+	}
+
+	if s.Error != nil {
+		a.Errors[failure.Localize(s.Error, r.Locale)]++
+		a.Kinds[failure.KindOf(s.Error)]++
+		r.addEntry(s)
+	}
+
+	a.Score += int64(s.Score)
+	a.hist.Record(s.Elapsed)
+
+	if len(s.Tier) != 0 {
+		if a.Tiers == nil {
+			a.Tiers = make(map[string]int64)
+		}
+		if r.Tiers == nil {
+			r.Tiers = make(map[string]int64)
+		}
+		a.Tiers[s.Tier]++
+		r.Tiers[s.Tier]++
+	}
+
+	r.tallies.Add(s)
+}
+
+// Adjust records a change of the score outside the action results
+func (r *Report) Adjust(delta int64) {
+	defer r.l.Unlock()
+	r.l.Lock()
+	r.Adjustment += delta
+}
+
+// Rescore returns the score of the run under rs. Failed runs stay at 0.
+func (r *Report) Rescore(rs *score.RuleSet) int64 {
+	if r.Status == StatusFail {
+		return 0
+	}
+
+	total := rs.Total(r.Tallies) + r.Adjustment
+	if total < 0 {
+		return 0
+	}
+
+	return total
+}
+
+func (r *Report) addEntry(s score.Score) {
+	if len(r.Transcript) >= r.limit {
+		r.TranscriptDropped++
+		return
+	}
+
+	e := Entry{
+		At:      time.Since(r.Started).Seconds(),
+		Action:  s.Name,
+		Method:  s.Method,
+		Elapsed: ms(s.Elapsed),
+		Error:   failure.Localize(s.Error, r.Locale),
+		Kind:    failure.KindOf(s.Error),
+	}
+
+	if f, ok := failure.As(s.Error); ok {
+		e.URL = f.URL
+		e.Status = f.Status
+		e.Selector = f.Selector
+	}
+
+	if ex := s.Exchange; ex != nil {
+		e.Method = ex.Method
+		e.URL = ex.URL
+		e.Status = ex.Status
+		e.Snippet = ex.Snippet
+		e.Elapsed = ms(ex.Elapsed)
+	}
+
+	r.Transcript = append(r.Transcript, e)
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Finish fixes the total score, status and failure reason and summarizes
+// the latencies
+func (r *Report) Finish(total int64, status, reason string, rec *stats.Recorder) {
+	defer r.l.Unlock()
+	r.l.Lock()
+
+	r.Score = total
+	r.Status = status
+	r.Reason = reason
+	r.Duration = time.Since(r.Started).String()
+
+	for _, a := range r.Actions {
+		a.Latency = a.hist.Summary()
+	}
+
+	r.Tallies = r.tallies.List()
+
+	if rec != nil {
+		r.Endpoints = rec.Endpoints()
+		r.Timeline = rec.Timeline()
+		r.Conns = rec.Connections()
+	}
+}
+
+// TranscriptJSON returns the transcript alone for the score table
+func (r *Report) TranscriptJSON() ([]byte, error) {
+	defer r.l.Unlock()
+	r.l.Lock()
+	if r.Transcript == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(r.Transcript)
+}
+
+// JSON returns the indented report
+func (r *Report) JSON() ([]byte, error) {
+	defer r.l.Unlock()
+	r.l.Lock()
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// WriteFile writes the report to path, or to stdout when path is "-"
+func (r *Report) WriteFile(path string) error {
+	buf, err := r.JSON()
+	if err != nil {
+		return err
+	}
+
+	w, err := create(path)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	_, err = w.Write(append(buf, '\n'))
+
+	return err
+}
+
+// WriteTimelineFile writes the timeline as CSV to path, or to stdout when path is "-"
+func (r *Report) WriteTimelineFile(path string) error {
+	defer r.l.Unlock()
+	r.l.Lock()
+
+	w, err := create(path)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	return stats.WriteTimelineCSV(w, r.Timeline)
+}
+
+type stdout struct {
+	io.Writer
+}
+
+func (stdout) Close() error {
+	return nil
+}
+
+// ReadFile reads a report written by WriteFile, from stdin when path is "-"
+func ReadFile(path string) (*Report, error) {
+	var r io.Reader = os.Stdin
+
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	rep := new(Report)
+
+	if err := json.NewDecoder(r).Decode(rep); err != nil {
+		return nil, err
+	}
+
+	return rep, nil
+}
+
+// create opens path for writing, or stdout when path is "-"
+func create(path string) (io.WriteCloser, error) {
+	if path == "-" {
+		return stdout{os.Stdout}, nil
+	}
+	return os.Create(path)
+}
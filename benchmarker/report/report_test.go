@@ -0,0 +1,42 @@
+package report
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/yahoojapan/yisucon/benchmarker/score"
+)
+
+func TestRescore(t *testing.T) {
+	tallies := []score.Tally{
+		{Action: "MyPageCheck", Method: http.MethodGet, Outcome: score.OutcomeSuccess, Units: 1, Latency: 10, Count: 50},
+		{Action: "LoginCheck", Method: http.MethodPost, Outcome: score.OutcomeSuccess, Units: 1, Latency: 2000, Count: 10},
+		{Action: "MyPageCheck", Method: http.MethodGet, Outcome: score.CategoryError, Latency: 10, Count: 2},
+	}
+
+	tiers, err := score.ParseTiers("100ms:4,1s:2,3s:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		status     string
+		adjustment int64
+		rs         *score.RuleSet
+		want       int64
+	}{
+		{"count", StatusPass, 0, score.DefaultRules, 50 + 20 - 20},
+		{"latency", StatusPass, 0, score.DefaultRules.WithTiers(tiers), 200 + 20 - 20},
+		{"adjustment", StatusPass, 100, score.DefaultRules, 150},
+		{"negative", StatusPass, -1000, score.DefaultRules, 0},
+		{"fail", StatusFail, 0, score.DefaultRules, 0},
+	}
+
+	for _, tt := range tests {
+		r := &Report{Status: tt.status, Adjustment: tt.adjustment, Tallies: tallies}
+		if got := r.Rescore(tt.rs); got != tt.want {
+			t.Errorf("%s : Rescore() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -2,81 +2,354 @@ package runner
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gocraft/dbr"
 
 	"github.com/yahoojapan/yisucon/benchmarker/config"
-	"github.com/yahoojapan/yisucon/benchmarker/db"
+	"github.com/yahoojapan/yisucon/benchmarker/job"
 	"github.com/yahoojapan/yisucon/benchmarker/logger"
+	"github.com/yahoojapan/yisucon/benchmarker/message"
+	"github.com/yahoojapan/yisucon/benchmarker/metrics"
 	"github.com/yahoojapan/yisucon/benchmarker/model"
 	"github.com/yahoojapan/yisucon/benchmarker/processor"
 )
 
-func Run() error {
+// Run benchmarks the jobs of src until it fails, ctx is done or, for a finite
+// source, until every job is done. Once ctx is done no job is claimed and the
+// running benches save their partial results before Run returns. A failure
+// cancels the running benches the same way before it is returned.
+func Run(parent context.Context, src job.Source) error {
 	l := logger.GetLogger()
 
+	l.Printf("config : %s\n", config.Current().JSON())
+
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	n := parallelism()
+
+	l.Printf("runner : %d parallel benches\n", n)
+
+	slots := make(chan struct{}, n)
+	errc := make(chan error, n)
+
 	for {
-		q, err := db.QueueChecker(config.QueueCheckDuration)
+		// wait for a free slot before claiming the next job
+		select {
+		case slots <- struct{}{}:
+		case err := <-errc:
+			l.Println("runner : stopping running benches")
+			return wait(cancel, slots, errc, err)
+		case <-ctx.Done():
+			l.Println("runner : waiting for running benches")
+			return wait(cancel, slots, errc, nil)
+		}
+
+		q, err := src.Next(ctx)
+
+		if err == io.EOF || ctx.Err() != nil {
+			<-slots
+			if ctx.Err() != nil {
+				l.Println("runner : waiting for running benches")
+			}
+			return wait(cancel, slots, errc, nil)
+		}
 
 		if err != nil {
-			return err
+			<-slots
+			l.Println("runner : stopping running benches")
+			return wait(cancel, slots, errc, err)
 		}
 
-		l.Printf("BENCH team#%d Started...\n", q.TeamID.Int64)
+		go func(q *model.TeamQueue) {
+			defer func() {
+				<-slots
+			}()
+			if err := bench(ctx, src, q, n > 1); err != nil {
+				errc <- err
+			}
+		}(q)
+	}
+}
 
-		score := &model.Score{
-			Score:   dbr.NewNullInt64(0),
-			QueueID: q.QueueID,
-			Message: dbr.NewNullString(""),
+// wait blocks until every running bench has released its slot and returns
+// err or else the first error of a bench. An error cancels the benches still
+// running so that they save their partial results and release their jobs.
+func wait(cancel context.CancelFunc, slots chan struct{}, errc chan error, err error) error {
+	if err != nil {
+		cancel()
+	}
+
+	for i := 0; i < cap(slots); {
+		select {
+		case slots <- struct{}{}:
+			i++
+		case e := <-errc:
+			if err == nil {
+				err = e
+				cancel()
+			}
+		}
+	}
+
+	select {
+	case e := <-errc:
+		if err == nil {
+			err = e
 		}
+	default:
+	}
+
+	return err
+}
+
+// parallelism returns the number of concurrent benches. It is capped by the
+// number of CPUs so that benches do not distort each other's timing.
+func parallelism() int {
+	n := config.MaxParallelBenches
+
+	if cpu := runtime.NumCPU(); n > cpu {
+		logger.GetLogger().Printf("runner : max_parallel_benches is capped to %d CPUs\n", cpu)
+		n = cpu
+	}
+
+	return n
+}
+
+// bench runs the job q and hands its result back to src. parallel tells that
+// other benches run at the same time and write their own report files.
+func bench(ctx context.Context, src job.Source, q *model.TeamQueue, parallel bool) error {
+	l := logger.GetLogger().With("team_id", q.TeamID.Int64).With("queue_id", q.QueueID.Int64)
+
+	l.Printf("BENCH team#%d Started...\n", q.TeamID.Int64)
+
+	if q.Attempts.Int64 > 1 {
+		l.Printf("runner : attempt %d\n", q.Attempts.Int64)
+	}
 
-		q.Host.String = strings.NewReplacer("http://", "",
-			"https://", "").Replace(q.Host.String)
+	stop := heartbeat(src, q, l)
+
+	if q.Date.Valid {
+		metrics.QueueWait.Observe(time.Since(q.Date.Time).Seconds())
+	}
+
+	start := time.Now()
+	metrics.RunsInProgress.Inc()
+
+	loc := locale(q, l)
+
+	score := &model.Score{
+		Score:   dbr.NewNullInt64(0),
+		QueueID: q.QueueID,
+		Message: dbr.NewNullString(""),
+		Status:  dbr.NewNullString(model.StatusPass),
+		Locale:  loc,
+	}
 
-		if err = initialize(q.Host.String, q.TeamID.Int64, time.Second*10); err != nil {
-			l.Println("runner : initialize error")
-			l.Println(err)
+	q.Host.String = trimScheme(q.Host.String)
+
+	if err := initialize(q.Host.String, q.TeamID.Int64, config.InitializeTimeout); err != nil {
+		l.Errorf("runner : initialize error : %v", err)
+		score.Errors = append(score.Errors, &model.Error{
+			Error:   err,
+			Message: err.Error(),
+		})
+	} else {
+		if p, err := processor.NewProcessor(q.Host.String, loc, l); err != nil {
+			l.Error(err)
 			score.Errors = append(score.Errors, &model.Error{
 				Error:   err,
 				Message: err.Error(),
 			})
 		} else {
-			if p, err := processor.NewProcessor(q.Host.String); err != nil {
-				l.Println(err)
-				score.Errors = append(score.Errors, &model.Error{
-					Error:   err,
-					Message: err.Error(),
-				})
-			} else {
-				score = p.Run(config.BenchTimeLimit)
-				score.QueueID = q.QueueID
-				l.Printf("Score : %d\n", score.Score.Int64)
+			score = p.Run(ctx, config.BenchTimeLimit)
+			score.QueueID = q.QueueID
+			score.Report.TeamID = q.TeamID.Int64
+			score.Report.QueueID = q.QueueID.Int64
+			l.Printf("Score : %d\n", score.Score.Int64)
+		}
+	}
+
+	if ctx.Err() != nil && score.Status.String == model.StatusPass {
+		score.Status = dbr.NewNullString(model.StatusInterrupted)
+	}
+
+	if err := finalize(q.Host.String, q.TeamID.Int64); err != nil {
+		l.Errorf("runner : finalize error : %v", err)
+		score.Errors = append(score.Errors, &model.Error{
+			Error:   err,
+			Message: err.Error(),
+		})
+	}
+
+	score.Config = dbr.NewNullString(config.Current().JSON())
+
+	metrics.RunsInProgress.Dec()
+	metrics.RunDuration.Observe(time.Since(start).Seconds())
+	metrics.Scores.WithLabelValues(strconv.FormatInt(q.TeamID.Int64, 10)).Observe(float64(score.Score.Int64))
+
+	if parallel {
+		writeReport(score, fmt.Sprintf("team%d-queue%d", q.TeamID.Int64, q.QueueID.Int64))
+	} else {
+		writeReport(score, "")
+	}
+
+	stop()
+
+	if err := src.Done(q, score); err == job.ErrLeaseLost {
+		l.Warn("runner : the job was taken over, result dropped")
+	} else if err != nil {
+		return err
+	}
+
+	l.Printf("BENCH team#%d Done.\n", q.TeamID.Int64)
+
+	return nil
+}
+
+// locale is the language of the team of q, config.Locale unless it has a
+// supported one
+func locale(q *model.TeamQueue, l *logger.Logger) string {
+	if len(q.Locale.String) == 0 {
+		return config.Locale
+	}
+
+	if !message.Supported(q.Locale.String) {
+		l.Warnf("runner : unknown locale %q, using %s", q.Locale.String, config.Locale)
+		return config.Locale
+	}
+
+	return q.Locale.String
+}
+
+// heartbeat renews the lease of q every HeartbeatInterval until the returned
+// func is called
+func heartbeat(src job.Source, q *model.TeamQueue, l *logger.Logger) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(config.HeartbeatInterval)
+
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				switch err := src.Renew(q); err {
+				case nil:
+					metrics.Heartbeats.WithLabelValues("ok").Inc()
+				case job.ErrLeaseLost:
+					metrics.Heartbeats.WithLabelValues("lost").Inc()
+					l.Warn("runner : lease lost")
+				default:
+					metrics.Heartbeats.WithLabelValues("error").Inc()
+					l.Errorf("runner : heartbeat error : %v", err)
+				}
 			}
 		}
+	}()
 
-		if err = finalize(q.Host.String, q.TeamID.Int64); err != nil {
-			l.Println("runner : finalize error")
-			l.Println(err)
-			score.Errors = append(score.Errors, &model.Error{
-				Error:   err,
-				Message: err.Error(),
-			})
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// RunStandalone benchmarks host once without touching the portal or the queue DB
+func RunStandalone(ctx context.Context, host string) *model.Score {
+	host = trimScheme(host)
+
+	l := logger.GetLogger().With("target", host)
+
+	l.Printf("config : %s\n", config.Current().JSON())
+	l.Printf("BENCH %s Started...\n", host)
+
+	score := &model.Score{
+		Score:   dbr.NewNullInt64(0),
+		Message: dbr.NewNullString(""),
+		Status:  dbr.NewNullString(model.StatusPass),
+		Locale:  config.Locale,
+	}
+
+	if p, err := processor.NewProcessor(host, config.Locale, l); err != nil {
+		l.Error(err)
+		score.Errors = append(score.Errors, &model.Error{
+			Error:   err,
+			Message: err.Error(),
+		})
+	} else {
+		score = p.Run(ctx, config.BenchTimeLimit)
+		l.Printf("Score : %d\n", score.Score.Int64)
+	}
+
+	score.Config = dbr.NewNullString(config.Current().JSON())
+	score.CreateErrMessage()
+
+	writeReport(score, "")
+
+	l.Printf("BENCH %s Done.\n", host)
+
+	return score
+}
+
+// writeReport writes the report and the timeline of score to the configured
+// paths. A non-empty suffix goes into the file names so that concurrent
+// benches do not overwrite each other's files.
+func writeReport(score *model.Score, suffix string) {
+	if score.Report == nil {
+		return
+	}
+
+	if len(config.ReportPath) != 0 {
+		if err := score.Report.WriteFile(withSuffix(config.ReportPath, suffix)); err != nil {
+			logger.GetLogger().Error(err)
 		}
+	}
 
-		if err = db.SaveResult(q.TeamID.Int64, score); err != nil {
-			return err
+	if len(config.TimelinePath) != 0 {
+		if err := score.Report.WriteTimelineFile(withSuffix(config.TimelinePath, suffix)); err != nil {
+			logger.GetLogger().Error(err)
 		}
+	}
+}
 
-		l.Printf("BENCH team#%d Done.\n", q.TeamID.Int64)
+// withSuffix inserts suffix before the extension of path, report.json becomes
+// report.team1-queue2.json. stdout is left as is.
+func withSuffix(path, suffix string) string {
+	if len(suffix) == 0 || path == "-" {
+		return path
 	}
+
+	ext := filepath.Ext(path)
+
+	return strings.TrimSuffix(path, ext) + "." + suffix + ext
+}
+
+// trimScheme drops the default http scheme of a team host. https is kept so
+// that the sessions connect with TLS.
+func trimScheme(host string) string {
+	return strings.TrimPrefix(host, "http://")
 }
 
 func initialize(host string, teamID int64, dur time.Duration) error {
+	if len(config.PortalHost) == 0 {
+		return nil
+	}
 
 	val, err := json.Marshal(&model.ProtalHook{
 		TeamID: teamID,
@@ -87,7 +360,9 @@ func initialize(host string, teamID int64, dur time.Duration) error {
 		return err
 	}
 
-	resp, err := http.DefaultClient.Post(fmt.Sprintf("http://%s/%s/%d", config.PortalHost, "api/benches", teamID), "application/json", bytes.NewReader(val))
+	client := &http.Client{Timeout: dur}
+
+	resp, err := client.Post(fmt.Sprintf("http://%s/%s/%d", config.PortalHost, "api/benches", teamID), "application/json", bytes.NewReader(val))
 
 	if err != nil {
 		return err
@@ -99,6 +374,9 @@ func initialize(host string, teamID int64, dur time.Duration) error {
 }
 
 func finalize(host string, teamID int64) error {
+	if len(config.PortalHost) == 0 {
+		return nil
+	}
 
 	val, err := json.Marshal(&model.ProtalHook{
 		TeamID: teamID,
@@ -0,0 +1,186 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gocraft/dbr"
+
+	"github.com/yahoojapan/yisucon/benchmarker/config"
+	"github.com/yahoojapan/yisucon/benchmarker/job"
+	"github.com/yahoojapan/yisucon/benchmarker/model"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "runner")
+	if err != nil {
+		panic(err)
+	}
+
+	config.LogFilePath = filepath.Join(dir, "benchmarker.log")
+	config.LogLevel = "error"
+	config.BenchTimeLimit = time.Second
+	config.InitializeTimeout = time.Second
+	config.RequestTimeout = time.Second
+	config.VerifySample = 0
+
+	code := m.Run()
+
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestRunMemory(t *testing.T) {
+	// a target that is down fails the initial check at once
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusInternalServerError)
+	}))
+	defer target.Close()
+
+	tests := []struct {
+		name     string
+		parallel int
+		jobs     int
+	}{
+		{"no job", 1, 0},
+		{"one job", 1, 1},
+		{"sequential", 1, 3},
+		{"parallel", 2, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.MaxParallelBenches = tt.parallel
+
+			var jobs []*model.TeamQueue
+			for i := 1; i <= tt.jobs; i++ {
+				jobs = append(jobs, &model.TeamQueue{
+					TeamID:  dbr.NewNullInt64(int64(i)),
+					QueueID: dbr.NewNullInt64(int64(100 + i)),
+					Host:    dbr.NewNullString(target.URL),
+				})
+			}
+
+			src := job.NewMemory(jobs...)
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+			defer cancel()
+
+			if err := Run(ctx, src); err != nil {
+				t.Fatalf("Run() = %v", err)
+			}
+
+			if len(src.Results) != tt.jobs {
+				t.Fatalf("got %d results, want %d", len(src.Results), tt.jobs)
+			}
+
+			seen := make(map[int64]bool)
+
+			for _, res := range src.Results {
+				if res.QueueID != res.TeamID+100 {
+					t.Errorf("team %d got the result of queue %d", res.TeamID, res.QueueID)
+				}
+				if seen[res.QueueID] {
+					t.Errorf("queue %d benched twice", res.QueueID)
+				}
+				seen[res.QueueID] = true
+
+				if res.Status != model.StatusFail || res.Score != 0 {
+					t.Errorf("queue %d : got %s %d, want FAIL 0", res.QueueID, res.Status, res.Score)
+				}
+				if !strings.Contains(res.Message, "初期チェックに失敗しました") {
+					t.Errorf("queue %d : message %q does not tell the initial check failed", res.QueueID, res.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	config.MaxParallelBenches = 1
+
+	src := job.NewMemory(&model.TeamQueue{
+		TeamID:  dbr.NewNullInt64(1),
+		QueueID: dbr.NewNullInt64(1),
+		Host:    dbr.NewNullString("127.0.0.1:1"),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := Run(ctx, src); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	if len(src.Results) != 0 {
+		t.Errorf("a cancelled runner claimed %d jobs", len(src.Results))
+	}
+}
+
+// failing hands out the jobs of a Memory, then fails
+type failing struct {
+	*job.Memory
+}
+
+var errSource = errors.New("source failed")
+
+func (f *failing) Next(ctx context.Context) (*model.TeamQueue, error) {
+	q, err := f.Memory.Next(ctx)
+	if err == io.EOF {
+		return nil, errSource
+	}
+	return q, err
+}
+
+func TestRunFailure(t *testing.T) {
+	config.MaxParallelBenches = 2
+
+	// a target that hangs keeps the bench running until it is cancelled
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer target.Close()
+
+	src := &failing{job.NewMemory(&model.TeamQueue{
+		TeamID:  dbr.NewNullInt64(1),
+		QueueID: dbr.NewNullInt64(1),
+		Host:    dbr.NewNullString(target.URL),
+	})}
+
+	if err := Run(context.Background(), src); err != errSource {
+		t.Fatalf("Run() = %v, want %v", err, errSource)
+	}
+
+	if len(src.Results) != 1 {
+		t.Errorf("Run() returned before the running bench saved its result")
+	}
+}
+
+func TestWithSuffix(t *testing.T) {
+	tests := []struct {
+		path   string
+		suffix string
+		want   string
+	}{
+		{"report.json", "", "report.json"},
+		{"report.json", "team1-queue2", "report.team1-queue2.json"},
+		{"out/timeline.csv", "team1-queue2", "out/timeline.team1-queue2.csv"},
+		{"report", "team1-queue2", "report.team1-queue2"},
+		{"-", "team1-queue2", "-"},
+	}
+
+	for _, tt := range tests {
+		if got := withSuffix(tt.path, tt.suffix); got != tt.want {
+			t.Errorf("withSuffix(%q, %q) = %q, want %q", tt.path, tt.suffix, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package score
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/yahoojapan/yisucon/benchmarker/config"
+)
+
+// Outcome of an action besides the penalty categories
+const OutcomeSuccess = "success"
+
+// Rule gives the points of the action results it matches. Empty fields
+// match anything and the latency band is [MinLatency, MaxLatency), open
+// ended when MaxLatency is 0.
+type Rule struct {
+	Action     string        `yaml:"action" json:"action,omitempty"`
+	Method     string        `yaml:"method" json:"method,omitempty"`
+	Outcome    string        `yaml:"outcome" json:"outcome,omitempty"`
+	MinLatency time.Duration `yaml:"min_latency" json:"min_latency,omitempty"`
+	MaxLatency time.Duration `yaml:"max_latency" json:"max_latency,omitempty"`
+	// Points of a success are per unit the check returned, the points of a
+	// failure are given as is
+	Points int `yaml:"points" json:"points"`
+}
+
+// RuleSet is a versioned scoring table. The first matching rule scores a
+// result and results no rule matches score 0.
+type RuleSet struct {
+	Version string `yaml:"version" json:"version"`
+	Rules   []Rule `yaml:"rules" json:"rules"`
+	// Tiers multiply the points of successes by latency when not empty
+	Tiers []Tier `yaml:"tiers" json:"tiers,omitempty"`
+}
+
+// Tier multiplies the points of successes faster than MaxLatency and not
+// faster than the previous tier. Successes slower than the last tier score
+// 0. Tiers with an Action replace the others for that action.
+//
+// Multipliers are integers so that points stay exact, 4, 2 and 1 give full,
+// half and quarter points. Scores with tiers are on their own scale, the
+// version of the rule set tells them apart.
+type Tier struct {
+	Action     string        `yaml:"action" json:"action,omitempty"`
+	MaxLatency time.Duration `yaml:"max_latency" json:"max_latency"`
+	Multiplier int           `yaml:"multiplier" json:"multiplier"`
+}
+
+// DefaultRules is the scoring of the contest
+var DefaultRules = &RuleSet{
+	Version: "2017.1",
+	Rules: []Rule{
+		// -リクエスト失敗(exception)数 x 20
+		{Outcome: CategoryTimeout, Points: -20},
+		// -遅延POSTレスポンス数 x 100
+		{Outcome: CategoryPostTimeout, Points: -100},
+		// -サーバエラー(error)レスポンス数 x 10
+		{Outcome: CategoryError, Points: -10},
+		// 成功レスポンス数(GET) x 1
+		{Outcome: OutcomeSuccess, Method: http.MethodGet, Points: 1},
+		// 成功レスポンス数(POST) x 2
+		{Outcome: OutcomeSuccess, Method: http.MethodPost, Points: 2},
+	},
+}
+
+var rules = DefaultRules
+
+// Rules returns the rule set CalcScore uses
+func Rules() *RuleSet {
+	return rules
+}
+
+// LoadRules replaces the rule set with the YAML file at path. An empty path
+// keeps DefaultRules.
+func LoadRules(path string) error {
+	if len(path) == 0 {
+		return nil
+	}
+
+	rs, err := ReadRules(path)
+	if err != nil {
+		return err
+	}
+
+	rules = rs
+
+	return nil
+}
+
+// ReadRules reads the YAML rule set at path
+func ReadRules(path string) (*RuleSet, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rs := new(RuleSet)
+
+	if err = yaml.UnmarshalStrict(buf, rs); err != nil {
+		return nil, fmt.Errorf("rules %s : %v", path, err)
+	}
+
+	if err = rs.Validate(); err != nil {
+		return nil, fmt.Errorf("rules %s : %v", path, err)
+	}
+
+	return rs, nil
+}
+
+// Validate checks the version, outcomes and latency bands of the rules
+func (rs *RuleSet) Validate() error {
+	if len(rs.Version) == 0 {
+		return fmt.Errorf("no version defined")
+	}
+
+	for i, r := range rs.Rules {
+		switch r.Outcome {
+		case "", OutcomeSuccess, CategoryError, CategoryTimeout, CategoryPostTimeout:
+		default:
+			return fmt.Errorf("rule %d : unknown outcome %q", i, r.Outcome)
+		}
+
+		if r.MinLatency < 0 || (r.MaxLatency != 0 && r.MaxLatency <= r.MinLatency) {
+			return fmt.Errorf("rule %d : invalid latency band", i)
+		}
+	}
+
+	last := make(map[string]time.Duration)
+
+	for i, t := range rs.Tiers {
+		if t.MaxLatency <= last[t.Action] || t.Multiplier < 0 {
+			return fmt.Errorf("tier %d : tiers must be ascending with non negative multipliers", i)
+		}
+		last[t.Action] = t.MaxLatency
+	}
+
+	return nil
+}
+
+// WithTiers returns a copy of rs scoring with tiers. Its version names the
+// tiers so that scores of different tier tables never share a version.
+func (rs *RuleSet) WithTiers(tiers []Tier) *RuleSet {
+	h := fnv.New32a()
+	for _, t := range tiers {
+		fmt.Fprintf(h, "%s:%s:%d,", t.Action, t.MaxLatency, t.Multiplier)
+	}
+
+	return &RuleSet{
+		Version: fmt.Sprintf("%s+latency-%08x", rs.Version, h.Sum32()),
+		Rules:   rs.Rules,
+		Tiers:   tiers,
+	}
+}
+
+// ParseTiers parses tiers such as "100ms:4,1s:2,3s:1"
+func ParseTiers(s string) ([]Tier, error) {
+	var tiers []Tier
+
+	for _, t := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(t), ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid tier %q", t)
+		}
+
+		bound, err := time.ParseDuration(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid tier %q : %v", t, err)
+		}
+
+		multiplier, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid tier %q : %v", t, err)
+		}
+
+		tiers = append(tiers, Tier{
+			MaxLatency: bound,
+			Multiplier: multiplier,
+		})
+	}
+
+	if err := (&RuleSet{Version: "-", Tiers: tiers}).Validate(); err != nil {
+		return nil, err
+	}
+
+	return tiers, nil
+}
+
+// Tier returns the label of the tier of a success of action, such as
+// "<100ms" or ">=3s", and its multiplier. Without tiers it returns "" and 1.
+func (rs *RuleSet) Tier(action string, latency time.Duration) (string, int) {
+	if len(rs.Tiers) == 0 {
+		return "", 1
+	}
+
+	var tiers []Tier
+
+	for _, t := range rs.Tiers {
+		if t.Action == action {
+			tiers = append(tiers, t)
+		}
+	}
+
+	if len(tiers) == 0 {
+		for _, t := range rs.Tiers {
+			if len(t.Action) == 0 {
+				tiers = append(tiers, t)
+			}
+		}
+	}
+
+	if len(tiers) == 0 {
+		return "", 1
+	}
+
+	for _, t := range tiers {
+		if latency < t.MaxLatency {
+			return "<" + t.MaxLatency.String(), t.Multiplier
+		}
+	}
+
+	return ">=" + tiers[len(tiers)-1].MaxLatency.String(), 0
+}
+
+// SetMode applies the scoring mode to the current rules. The latency mode
+// adds tiers to a rule table without tiers of its own, a rule table with
+// tiers scores by latency in any mode.
+func SetMode(mode, tiers string) error {
+	if mode != config.ScoringModeLatency || len(rules.Tiers) != 0 {
+		return nil
+	}
+
+	t, err := ParseTiers(tiers)
+	if err != nil {
+		return err
+	}
+
+	rules = rules.WithTiers(t)
+
+	return nil
+}
+
+// Points scores one result of action. units is what a successful check
+// returned, results with no unit score 0 like before the rule table.
+func (rs *RuleSet) Points(action, method, outcome string, units int, latency time.Duration) int {
+	if outcome == OutcomeSuccess && units <= 0 {
+		return 0
+	}
+
+	for _, r := range rs.Rules {
+		if !r.matches(action, method, outcome, latency) {
+			continue
+		}
+		if outcome == OutcomeSuccess {
+			_, multiplier := rs.Tier(action, latency)
+			return units * r.Points * multiplier
+		}
+		return r.Points
+	}
+
+	return 0
+}
+
+func (r *Rule) matches(action, method, outcome string, latency time.Duration) bool {
+	switch {
+	case len(r.Action) != 0 && r.Action != action:
+		return false
+	case len(r.Method) != 0 && r.Method != method:
+		return false
+	case len(r.Outcome) != 0 && r.Outcome != outcome:
+		return false
+	case latency < r.MinLatency:
+		return false
+	case r.MaxLatency != 0 && latency >= r.MaxLatency:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,157 @@
+package score
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTiers(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []Tier
+		wantErr bool
+	}{
+		{"100ms:4,1s:2,3s:1", []Tier{{MaxLatency: 100 * time.Millisecond, Multiplier: 4}, {MaxLatency: time.Second, Multiplier: 2}, {MaxLatency: 3 * time.Second, Multiplier: 1}}, false},
+		{" 500ms:2 , 2s:1 ", []Tier{{MaxLatency: 500 * time.Millisecond, Multiplier: 2}, {MaxLatency: 2 * time.Second, Multiplier: 1}}, false},
+		{"1s:0", []Tier{{MaxLatency: time.Second, Multiplier: 0}}, false},
+		{"", nil, true},
+		{"100ms", nil, true},
+		{"100:4", nil, true},
+		{"100ms:x", nil, true},
+		{"1s:2,100ms:4", nil, true},
+		{"1s:2,1s:1", nil, true},
+		{"1s:-1", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTiers(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTiers(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseTiers(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rs      RuleSet
+		wantErr bool
+	}{
+		{"default", *DefaultRules, false},
+		{"no version", RuleSet{Rules: DefaultRules.Rules}, true},
+		{"unknown outcome", RuleSet{Version: "1", Rules: []Rule{{Outcome: "slow"}}}, true},
+		{"band", RuleSet{Version: "1", Rules: []Rule{{MinLatency: time.Second, MaxLatency: 2 * time.Second}}}, false},
+		{"empty band", RuleSet{Version: "1", Rules: []Rule{{MinLatency: time.Second, MaxLatency: time.Second}}}, true},
+		{"negative band", RuleSet{Version: "1", Rules: []Rule{{MinLatency: -time.Second}}}, true},
+		{"tiers per action", RuleSet{Version: "1", Tiers: []Tier{{MaxLatency: time.Second}, {Action: "TweetCheck", MaxLatency: 500 * time.Millisecond}}}, false},
+	}
+
+	for _, tt := range tests {
+		if err := tt.rs.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s : Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPoints(t *testing.T) {
+	tiers, err := ParseTiers("100ms:4,1s:2,3s:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	latency := DefaultRules.WithTiers(append(tiers, Tier{Action: "TweetCheck", MaxLatency: 50 * time.Millisecond, Multiplier: 8}))
+
+	banded := &RuleSet{
+		Version: "banded",
+		Rules: []Rule{
+			{Action: "TweetCheck", Outcome: OutcomeSuccess, MaxLatency: 200 * time.Millisecond, Points: 5},
+			{Outcome: OutcomeSuccess, Points: 1},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		rs      *RuleSet
+		action  string
+		method  string
+		outcome string
+		units   int
+		latency time.Duration
+		want    int
+	}{
+		{"get", DefaultRules, "MyPageCheck", http.MethodGet, OutcomeSuccess, 1, time.Second, 1},
+		{"post", DefaultRules, "LoginCheck", http.MethodPost, OutcomeSuccess, 1, time.Second, 2},
+		{"units", DefaultRules, "PagingCheck", http.MethodGet, OutcomeSuccess, 3, time.Second, 3},
+		{"no unit", DefaultRules, "FaviconCheck", http.MethodGet, OutcomeSuccess, 0, time.Second, 0},
+		{"error", DefaultRules, "MyPageCheck", http.MethodGet, CategoryError, 0, time.Second, -10},
+		{"timeout", DefaultRules, "MyPageCheck", http.MethodGet, CategoryTimeout, 0, time.Second, -20},
+		{"post timeout", DefaultRules, "LoginCheck", http.MethodPost, CategoryPostTimeout, 0, time.Second, -100},
+		{"fast", latency, "MyPageCheck", http.MethodGet, OutcomeSuccess, 1, 50 * time.Millisecond, 4},
+		{"tier bound", latency, "MyPageCheck", http.MethodGet, OutcomeSuccess, 1, 100 * time.Millisecond, 2},
+		{"slow post", latency, "LoginCheck", http.MethodPost, OutcomeSuccess, 1, 2 * time.Second, 2},
+		{"too slow", latency, "MyPageCheck", http.MethodGet, OutcomeSuccess, 1, 3 * time.Second, 0},
+		{"tiers keep penalties", latency, "MyPageCheck", http.MethodGet, CategoryError, 0, 3 * time.Second, -10},
+		{"action tier", latency, "TweetCheck", http.MethodGet, OutcomeSuccess, 1, 40 * time.Millisecond, 8},
+		{"action tier only", latency, "TweetCheck", http.MethodGet, OutcomeSuccess, 1, 60 * time.Millisecond, 0},
+		{"band", banded, "TweetCheck", http.MethodGet, OutcomeSuccess, 2, 100 * time.Millisecond, 10},
+		{"past band", banded, "TweetCheck", http.MethodGet, OutcomeSuccess, 2, 200 * time.Millisecond, 2},
+		{"unmatched", banded, "TweetCheck", http.MethodGet, CategoryError, 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		if got := tt.rs.Points(tt.action, tt.method, tt.outcome, tt.units, tt.latency); got != tt.want {
+			t.Errorf("%s : Points() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTier(t *testing.T) {
+	tiers, err := ParseTiers("100ms:4,1s:2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rs := DefaultRules.WithTiers(tiers)
+
+	tests := []struct {
+		rs         *RuleSet
+		latency    time.Duration
+		label      string
+		multiplier int
+	}{
+		{DefaultRules, time.Second, "", 1},
+		{rs, 10 * time.Millisecond, "<100ms", 4},
+		{rs, 500 * time.Millisecond, "<1s", 2},
+		{rs, 2 * time.Second, ">=1s", 0},
+	}
+
+	for _, tt := range tests {
+		label, multiplier := tt.rs.Tier("MyPageCheck", tt.latency)
+		if label != tt.label || multiplier != tt.multiplier {
+			t.Errorf("Tier(%v) = %q %d, want %q %d", tt.latency, label, multiplier, tt.label, tt.multiplier)
+		}
+	}
+
+	other, err := ParseTiers("100ms:1,1s:2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	switch v := rs.Version; {
+	case !strings.HasPrefix(v, DefaultRules.Version+"+latency-"):
+		t.Errorf("WithTiers() version = %q", v)
+	case len(v) > 32:
+		t.Errorf("WithTiers() version %q does not fit score.rules_version", v)
+	case v != DefaultRules.WithTiers(tiers).Version:
+		t.Errorf("WithTiers() versions the same tiers apart")
+	case v == DefaultRules.WithTiers(other).Version:
+		t.Errorf("WithTiers() versions other tiers alike : %q", v)
+	}
+}
@@ -1,48 +1,76 @@
 package score
 
 import (
-	"net"
-	"net/http"
+	"time"
 
+	"github.com/yahoojapan/yisucon/benchmarker/failure"
 	"github.com/yahoojapan/yisucon/benchmarker/session"
 )
 
+// Penalty categories of a failed action
+const (
+	CategoryTimeout     = "timeout"
+	CategoryPostTimeout = "post_timeout"
+	CategoryError       = "error"
+)
+
 type Score struct {
-	Score int
-	Error error
+	Name     string
+	Method   string
+	Score    int
+	Error    error
+	Category string
+	Timeout  bool
+	Elapsed  time.Duration
+	// Units is what a successful check returned, scored per unit
+	Units int
+	// Tier is the latency tier of a success when the rules have tiers
+	Tier string
+	// Exchange is the last request of a failed action, if any
+	Exchange *session.Exchange
+}
+
+// Outcome is the penalty category of a failure or OutcomeSuccess
+func (s *Score) Outcome() string {
+	if s.Error == nil {
+		return OutcomeSuccess
+	}
+	return s.Category
 }
 
+// CalcScore runs f and scores its result with the current rule set
 func CalcScore(method, name string, f func() (int, error)) Score {
 
 	s := &Score{
-		Score: 0,
+		Name:   name,
+		Method: method,
+		Score:  0,
 	}
 
+	start := time.Now()
+
 	res, err := f()
 
+	s.Elapsed = time.Since(start)
+
 	if err != nil {
 		s.Error = err
-		if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
-			s.Error = nerr
-			// -リクエスト失敗(exception)数 x 20
-			s.Score = -20
-		} else if err == session.ErrPostTimeOut {
-			// -遅延POSTレスポンス数 x 100
-			s.Score = -100
-		} else {
-			// -サーバエラー(error)レスポンス数 x 10
-			s.Score = -10
-		}
-	} else {
 		switch {
-		case method == http.MethodGet && res > 0:
-			//成功レスポンス数(GET) x 1
-			s.Score = res
-		case method == http.MethodPost && res > 0:
-			//成功レスポンス数(POST) x 2
-			s.Score = res * 2
+		case err == session.ErrPostTimeOut:
+			s.Timeout = true
+			s.Category = CategoryPostTimeout
+		case failure.KindOf(err) == failure.Timeout:
+			s.Timeout = true
+			s.Category = CategoryTimeout
+		default:
+			s.Category = CategoryError
 		}
+	} else {
+		s.Units = res
+		s.Tier, _ = Rules().Tier(name, s.Elapsed)
 	}
 
+	s.Score = Rules().Points(name, method, s.Outcome(), s.Units, s.Elapsed)
+
 	return *s
 }
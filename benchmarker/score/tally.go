@@ -0,0 +1,87 @@
+package score
+
+import (
+	"sort"
+	"time"
+)
+
+// Tally counts the results of an action that any rule set scores alike.
+// Runs are rescored by applying a rule set to their tallies.
+type Tally struct {
+	Action  string `json:"action"`
+	Method  string `json:"method"`
+	Outcome string `json:"outcome"`
+	Units   int    `json:"units,omitempty"`
+	// Latency is the lower bound of the latency cell in ms, see Cell
+	Latency int64 `json:"latency_ms"`
+	Count   int64 `json:"count"`
+}
+
+// Cell rounds latency down to 10ms below 1s, to 100ms below 10s and to 1s
+// above. Latency bands on that grid rescore exactly.
+func Cell(latency time.Duration) time.Duration {
+	switch {
+	case latency < time.Second:
+		return latency.Truncate(time.Millisecond * 10)
+	case latency < time.Second*10:
+		return latency.Truncate(time.Millisecond * 100)
+	default:
+		return latency.Truncate(time.Second)
+	}
+}
+
+// Tallies aggregates scores into tallies
+type Tallies map[Tally]int64
+
+// Add counts s
+func (t Tallies) Add(s Score) {
+	key := Tally{
+		Action:  s.Name,
+		Method:  s.Method,
+		Outcome: s.Outcome(),
+		Latency: int64(Cell(s.Elapsed) / time.Millisecond),
+	}
+
+	if key.Outcome == OutcomeSuccess {
+		key.Units = s.Units
+	}
+
+	t[key]++
+}
+
+// List returns the tallies in a stable order
+func (t Tallies) List() []Tally {
+	res := make([]Tally, 0, len(t))
+
+	for key, count := range t {
+		key.Count = count
+		res = append(res, key)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		a, b := res[i], res[j]
+		switch {
+		case a.Action != b.Action:
+			return a.Action < b.Action
+		case a.Outcome != b.Outcome:
+			return a.Outcome < b.Outcome
+		case a.Latency != b.Latency:
+			return a.Latency < b.Latency
+		}
+		return a.Units < b.Units
+	})
+
+	return res
+}
+
+// Total scores tallies under rs
+func (rs *RuleSet) Total(tallies []Tally) int64 {
+	var total int64
+
+	for _, t := range tallies {
+		points := rs.Points(t.Action, t.Method, t.Outcome, t.Units, time.Duration(t.Latency)*time.Millisecond)
+		total += int64(points) * t.Count
+	}
+
+	return total
+}
@@ -0,0 +1,81 @@
+package score
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCell(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want time.Duration
+	}{
+		{0, 0},
+		{9 * time.Millisecond, 0},
+		{123 * time.Millisecond, 120 * time.Millisecond},
+		{999 * time.Millisecond, 990 * time.Millisecond},
+		{1234 * time.Millisecond, 1200 * time.Millisecond},
+		{9999 * time.Millisecond, 9900 * time.Millisecond},
+		{12345 * time.Millisecond, 12 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := Cell(tt.in); got != tt.want {
+			t.Errorf("Cell(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTallies(t *testing.T) {
+	scores := []Score{
+		{Name: "MyPageCheck", Method: http.MethodGet, Units: 1, Elapsed: 12 * time.Millisecond},
+		{Name: "MyPageCheck", Method: http.MethodGet, Units: 1, Elapsed: 18 * time.Millisecond},
+		{Name: "MyPageCheck", Method: http.MethodGet, Units: 1, Elapsed: 450 * time.Millisecond},
+		{Name: "PagingCheck", Method: http.MethodGet, Units: 3, Elapsed: 80 * time.Millisecond},
+		{Name: "LoginCheck", Method: http.MethodPost, Units: 1, Elapsed: 2 * time.Second},
+		{Name: "MyPageCheck", Method: http.MethodGet, Error: errors.New("ng"), Category: CategoryError, Units: 1, Elapsed: 15 * time.Millisecond},
+		{Name: "TweetCheck", Method: http.MethodPost, Error: errors.New("slow"), Category: CategoryPostTimeout, Elapsed: 31 * time.Second},
+	}
+
+	tallies := make(Tallies)
+	for _, s := range scores {
+		tallies.Add(s)
+	}
+
+	list := tallies.List()
+
+	// the first two MyPageCheck successes share a cell, failures drop units
+	if len(list) != 6 {
+		t.Fatalf("got %d tallies, want 6 : %+v", len(list), list)
+	}
+
+	for i := 1; i < len(list); i++ {
+		if list[i-1].Action > list[i].Action {
+			t.Errorf("tallies are not sorted : %+v", list)
+		}
+	}
+
+	tiers, err := ParseTiers("100ms:4,1s:2,3s:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rs := range []*RuleSet{DefaultRules, DefaultRules.WithTiers(tiers)} {
+		// scores on the cell grid rescore to the sum of their points
+		var want int64
+		for _, s := range scores {
+			want += int64(rs.Points(s.Name, s.Method, s.Outcome(), s.Units, Cell(s.Elapsed)))
+		}
+
+		if got := rs.Total(list); got != want {
+			t.Errorf("%s : Total() = %d, want %d", rs.Version, got, want)
+		}
+	}
+
+	// 3 MyPageCheck, 3 PagingCheck units, 1 LoginCheck POST, an error and a post timeout
+	if got := DefaultRules.Total(list); got != -102 {
+		t.Errorf("Total() = %d, want -102", got)
+	}
+}
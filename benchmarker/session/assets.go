@@ -0,0 +1,175 @@
+package session
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/yahoojapan/yisucon/benchmarker/failure"
+)
+
+// AssetCheck validates the body of a static asset fetched by a browser-like
+// session, e.g. against a known hash
+type AssetCheck func(u *url.URL, body []byte) error
+
+// asset is a subresource referenced by an HTML page
+type asset struct {
+	url *url.URL
+	// optional assets such as the implicit /favicon.ico may be missing
+	optional bool
+}
+
+// loadAssets reads the HTML page res and fetches the stylesheets, scripts,
+// images and favicon it references through the cache like a browser does.
+// res.Body is replaced so that the caller still reads the page.
+func (s *Session) loadAssets(req *http.Request, res *http.Response) error {
+	if mt, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type")); mt != "text/html" {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	if err != nil {
+		return failure.Request(req.URL.String(), err, "request.failed")
+	}
+
+	assets := parseAssets(req.URL, bytes.NewReader(body))
+
+	// the requests of the assets must not hide the page from LastExchange
+	page := s.lastExchange()
+
+	sem := make(chan struct{}, s.AssetParallel)
+	errc := make(chan error, len(assets))
+	wg := new(sync.WaitGroup)
+
+	for _, a := range assets {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(a asset) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := s.fetchAsset(a); err != nil {
+				errc <- err
+			}
+		}(a)
+	}
+
+	wg.Wait()
+	close(errc)
+
+	if err, ok := <-errc; ok {
+		return err
+	}
+
+	s.restoreExchange(page)
+
+	return nil
+}
+
+func (s *Session) fetchAsset(a asset) error {
+	res, err := s.SendSimpleRequest(http.MethodGet, a.url.String(), nil)
+	if err != nil {
+		if a.optional {
+			return nil
+		}
+		return failure.WithMessage(err, "asset.fetch", a.url.Path)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return failure.Request(a.url.String(), err, "asset.fetch", a.url.Path)
+	}
+
+	if s.CheckAsset != nil {
+		return s.CheckAsset(a.url, body)
+	}
+
+	return nil
+}
+
+// parseAssets returns the same-host subresources of the page at base, each
+// once. Without an icon link the page gets the implicit /favicon.ico.
+func parseAssets(base *url.URL, r io.Reader) []asset {
+	var (
+		assets []asset
+		seen   = make(map[string]bool)
+		icon   bool
+	)
+
+	add := func(ref string, optional bool) {
+		u, err := base.Parse(strings.TrimSpace(ref))
+		if err != nil || len(ref) == 0 || u.Host != base.Host || (u.Scheme != "http" && u.Scheme != "https") {
+			return
+		}
+		u.Fragment = ""
+		if seen[u.String()] {
+			return
+		}
+		seen[u.String()] = true
+		assets = append(assets, asset{url: u, optional: optional})
+	}
+
+	z := html.NewTokenizer(r)
+
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		t := z.Token()
+
+		switch t.DataAtom {
+		case atom.Link:
+			rel := strings.Fields(strings.ToLower(attr(t, "rel")))
+			for _, r := range rel {
+				switch r {
+				case "stylesheet":
+					add(attr(t, "href"), false)
+				case "icon":
+					icon = true
+					add(attr(t, "href"), false)
+				}
+			}
+		case atom.Script:
+			if src := attr(t, "src"); len(src) != 0 {
+				add(src, false)
+			}
+		case atom.Img:
+			if src := attr(t, "src"); len(src) != 0 {
+				add(src, false)
+			}
+		}
+	}
+
+	if !icon {
+		add("/favicon.ico", true)
+	}
+
+	return assets
+}
+
+func attr(t html.Token, key string) string {
+	for _, a := range t.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
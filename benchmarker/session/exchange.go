@@ -0,0 +1,122 @@
+package session
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// SnippetSize is the number of response body bytes kept in an Exchange
+const SnippetSize = 512
+
+// Exchange is a request of the session and what came back
+type Exchange struct {
+	Method  string        `json:"method"`
+	URL     string        `json:"url"`
+	Status  int           `json:"status"`
+	Snippet string        `json:"snippet,omitempty"`
+	Elapsed time.Duration `json:"-"`
+
+	body *snippet
+}
+
+// snippet keeps the first SnippetSize bytes read through a response body
+type snippet struct {
+	io.ReadCloser
+	l   *sync.Mutex
+	buf *bytes.Buffer
+}
+
+func newSnippet(body io.ReadCloser) *snippet {
+	return &snippet{
+		ReadCloser: body,
+		l:          new(sync.Mutex),
+		buf:        new(bytes.Buffer),
+	}
+}
+
+func (s *snippet) Read(p []byte) (int, error) {
+	n, err := s.ReadCloser.Read(p)
+	if n > 0 {
+		s.l.Lock()
+		if rest := SnippetSize - s.buf.Len(); rest > 0 {
+			if rest > n {
+				rest = n
+			}
+			s.buf.Write(p[:rest])
+		}
+		s.l.Unlock()
+	}
+	return n, err
+}
+
+func (s *snippet) String() string {
+	defer s.l.Unlock()
+	s.l.Lock()
+
+	buf := s.buf.Bytes()
+
+	// drop a character cut in half by SnippetSize
+	for len(buf) > 0 {
+		if r, size := utf8.DecodeLastRune(buf); r != utf8.RuneError || size != 1 {
+			break
+		}
+		buf = buf[:len(buf)-1]
+	}
+
+	return string(buf)
+}
+
+func (s *Session) setExchange(req *http.Request, status int, elapsed time.Duration, body *snippet) {
+	defer s.l.Unlock()
+	s.l.Lock()
+	s.last = &Exchange{
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Status:  status,
+		Elapsed: elapsed,
+		body:    body,
+	}
+}
+
+// LastExchange returns the latest request since ResetExchange, or nil. The
+// snippet holds what the checker read of the body so far.
+func (s *Session) LastExchange() *Exchange {
+	defer s.l.Unlock()
+	s.l.Lock()
+
+	if s.last == nil {
+		return nil
+	}
+
+	ex := *s.last
+	if ex.body != nil {
+		ex.Snippet = ex.body.String()
+		ex.body = nil
+	}
+
+	return &ex
+}
+
+func (s *Session) lastExchange() *Exchange {
+	defer s.l.Unlock()
+	s.l.Lock()
+	return s.last
+}
+
+func (s *Session) restoreExchange(ex *Exchange) {
+	defer s.l.Unlock()
+	s.l.Lock()
+	s.last = ex
+}
+
+// ResetExchange forgets the latest request, so that a failure without a
+// request does not report the one of a previous action
+func (s *Session) ResetExchange() {
+	defer s.l.Unlock()
+	s.l.Lock()
+	s.last = nil
+}
@@ -1,57 +1,83 @@
 package session
 
 import (
+	"bytes"
 	"compress/gzip"
 	"context"
 	"errors"
-	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/cookiejar"
+	"net/http/httptrace"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/yahoojapan/yisucon/benchmarker/cache"
 	"github.com/yahoojapan/yisucon/benchmarker/config"
+	"github.com/yahoojapan/yisucon/benchmarker/failure"
+	"github.com/yahoojapan/yisucon/benchmarker/metrics"
+	"github.com/yahoojapan/yisucon/benchmarker/stats"
 )
 
 var (
-	ErrPostTimeOut       = errors.New("post request timeout")
+	// ErrPostTimeOut is a POST that took longer than config.RequestTimeout,
+	// cut off by the client or answered late
+	ErrPostTimeOut       = failure.New(failure.Timeout, "", "request.post_timeout")
 	ErrBenchmarkerCancel = errors.New("Benchmarker Cancelled")
 )
 
 type Session struct {
+	// Host and Scheme are the target, every request to Host uses Scheme
 	Host      string
+	Scheme    string
 	Client    *http.Client
 	Transport *http.Transport
 	Cookies   []*http.Cookie
 	Cache     *cache.Cache
 	Storage   map[string]interface{}
+	Recorder  *stats.Recorder
+	// Template maps a request URL to the path template used as recorder key
+	Template func(*url.URL) string
+	// Browser makes the session fetch the assets of each HTML page with
+	// AssetParallel requests at a time, checked by CheckAsset
+	Browser       bool
+	AssetParallel int
+	CheckAsset    AssetCheck
 
 	cancel context.CancelFunc
 	ctx    context.Context
+	l      *sync.Mutex
+	last   *Exchange
 }
 
-func NewSession(ctx context.Context, host string) *Session {
+// NewSession returns a session against target, a host with an optional
+// http:// or https:// scheme
+func NewSession(ctx context.Context, target string) *Session {
 
 	jar, _ := cookiejar.New(&cookiejar.Options{})
 
-	tran := &http.Transport{
-		MaxIdleConns:        100,
-		MaxIdleConnsPerHost: 32,
-	}
+	tran := NewTransport()
+
+	scheme, host := ParseTarget(target)
 
 	sess := &Session{
 		Host:      host,
+		Scheme:    scheme,
 		Transport: tran,
 		Client: &http.Client{
 			Transport: tran,
 			Jar:       jar,
 			Timeout:   config.RequestTimeout,
 		},
-		Cache:   cache.NewCache(),
-		Storage: make(map[string]interface{}),
+		Cache:         cache.NewCache(config.CacheShared),
+		Storage:       make(map[string]interface{}),
+		Browser:       config.BrowserAssets,
+		AssetParallel: config.AssetParallel,
+		l:             new(sync.Mutex),
 	}
 
 	sess.ctx, sess.cancel = context.WithCancel(ctx)
@@ -70,28 +96,29 @@ func (s *Session) newRequest(method, uri string, body io.Reader) (*http.Request,
 	parsedURL, err := url.Parse(uri)
 
 	if err != nil {
-		return nil, fmt.Errorf("不正なURLです")
-	}
-
-	if parsedURL.Scheme == "" {
-		parsedURL.Scheme = "http"
+		return nil, failure.New(failure.Unknown, "", "request.invalid_url")
 	}
 
 	if parsedURL.Host == "" {
 		parsedURL.Host = s.Host
 	}
 
+	// checkers build http:// URLs, the target decides the scheme
+	if parsedURL.Scheme == "" || parsedURL.Host == s.Host {
+		parsedURL.Scheme = s.Scheme
+	}
+
 	req, err := http.NewRequest(method, parsedURL.String(), body)
 
 	if err != nil {
-		return nil, fmt.Errorf("リクエストに失敗しました")
+		return nil, failure.New(failure.Unknown, "", "request.failed")
 	}
 
 	req.Header.Del("User-Agent")
 	req.Header.Set("User-Agent", config.BenchMarkerUA)
 	req.Header.Add("Accept-Encoding", "gzip")
 
-	req.WithContext(s.ctx)
+	req = req.WithContext(s.ctx)
 
 	return req, nil
 }
@@ -103,37 +130,70 @@ func (s *Session) doRequest(req *http.Request) (*http.Response, error) {
 
 	start := time.Now()
 
-	if val, ok := s.Cache.Get(req); ok {
-		res = val.Resp
+	entry, cached := s.Cache.Get(req)
+
+	if cached && entry.IsValid() {
+		res = entry.Response(req)
 	} else {
+		if cached {
+			entry.Conditional(req)
+		}
+
+		var reused bool
+
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) {
+				reused = info.Reused
+			},
+		}))
+
 		res, err = s.Client.Do(req)
+		if err == nil {
+			s.conn(reused, res.Proto)
+		}
 		if err != nil {
-			return nil, fmt.Errorf("リクエストに失敗しました")
+			s.record(req, 0, time.Since(start), true)
+			s.setExchange(req, 0, time.Since(start), nil)
+			ferr := failure.Request(req.URL.String(), err, "request.failed")
+			// a POST the client gave up on while the session runs is too slow
+			if req.Method == http.MethodPost && ferr.Kind == failure.Timeout && s.ctx.Err() == nil {
+				return nil, ErrPostTimeOut
+			}
+			return nil, ferr
 		}
 
-		if res.StatusCode/100 == 3 {
-			rreq := *req
-			rreq.URL, err = url.ParseRequestURI(res.Header.Get("Location"))
-			if err == nil {
-				rres, err := s.Client.Transport.RoundTrip(&rreq)
-				if err == nil && rres.StatusCode/100 == 2 {
-					res = rres
+		if res.StatusCode == http.StatusNotModified && cached {
+			s.record(req, res.StatusCode, time.Since(start), false)
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+			res = s.Cache.Revalidated(req, entry, res).Response(req)
+		} else {
+			if res.StatusCode/100 == 3 {
+				rreq := *req
+				rreq.URL, err = url.ParseRequestURI(res.Header.Get("Location"))
+				if err == nil {
+					rres, err := s.Client.Transport.RoundTrip(&rreq)
+					if err == nil && rres.StatusCode/100 == 2 {
+						res = rres
+					}
 				}
 			}
-		}
 
-		if res.StatusCode/100 == 2 {
-			go func() {
-				data, err := cache.NewHTTPCache(res)
-				if data != nil && err == nil && s.Cache.Data != nil {
-					s.Cache.Set(req, data)
+			s.record(req, res.StatusCode, time.Since(start), res.StatusCode/100 != 2 && res.StatusCode/100 != 3)
+
+			// a redirected response belongs to another URL
+			if res.StatusCode == http.StatusOK && cache.Cacheable(req) && sameURL(req, res) {
+				if err := s.store(req, res); err != nil {
+					s.setExchange(req, res.StatusCode, time.Since(start), nil)
+					return nil, failure.Request(req.URL.String(), err, "request.failed")
 				}
-			}()
+			}
 		}
 	}
 
 	if res.StatusCode/100 != 2 && res.StatusCode/100 != 3 {
-		return nil, fmt.Errorf("リクエストに失敗しました。\n%s", res.Status)
+		s.keepErrorBody(req, res, time.Since(start))
+		return nil, failure.Status(req.URL.String(), res.StatusCode, "request.status", res.Status)
 	}
 
 	end := time.Since(start)
@@ -141,18 +201,100 @@ func (s *Session) doRequest(req *http.Request) (*http.Response, error) {
 	if res.Header.Get("Content-Encoding") == "gzip" {
 		gres, err := gzip.NewReader(res.Body)
 		if err != nil {
-			return res, err
+			s.setExchange(req, res.StatusCode, end, nil)
+			e := failure.Wrap(failure.ContentMismatch, err)
+			e.URL, e.Status = req.URL.String(), res.StatusCode
+			return res, e
 		}
 		res.Body = gres
 	}
 
+	body := newSnippet(res.Body)
+	res.Body = body
+	s.setExchange(req, res.StatusCode, end, body)
+
 	if req.Method == http.MethodPost && end > config.RequestTimeout {
 		return res, ErrPostTimeOut
 	}
 
+	if s.Browser && res.StatusCode == http.StatusOK {
+		if err := s.loadAssets(req, res); err != nil {
+			return nil, err
+		}
+	}
+
 	return res, nil
 }
 
+// store keeps res in the cache when its headers allow it. The body is read
+// whole and replaced by the copy so that the caller still reads it.
+func (s *Session) store(req *http.Request, res *http.Response) error {
+	if len(res.Header.Get("Cache-Control")) == 0 && len(res.Header.Get("Expires")) == 0 &&
+		len(res.Header.Get("ETag")) == 0 && len(res.Header.Get("Last-Modified")) == 0 {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	if data, err := cache.NewHTTPCache(req, res, body, s.Cache.Shared); err == nil {
+		s.Cache.Set(req, data)
+	}
+
+	return nil
+}
+
+func sameURL(req *http.Request, res *http.Response) bool {
+	return res.Request == nil || res.Request.URL.String() == req.URL.String()
+}
+
+// keepErrorBody reads the head of an error response into the last exchange
+// and closes it since the caller never sees the response
+func (s *Session) keepErrorBody(req *http.Request, res *http.Response, elapsed time.Duration) {
+	defer res.Body.Close()
+
+	var r io.Reader = res.Body
+
+	if res.Header.Get("Content-Encoding") == "gzip" {
+		if gres, err := gzip.NewReader(res.Body); err == nil {
+			r = gres
+		}
+	}
+
+	body := newSnippet(ioutil.NopCloser(r))
+	io.CopyN(ioutil.Discard, body, SnippetSize)
+
+	s.setExchange(req, res.StatusCode, elapsed, body)
+}
+
+// conn counts a response by the reuse of its connection and its protocol
+func (s *Session) conn(reused bool, proto string) {
+	metrics.Connections.WithLabelValues(strconv.FormatBool(reused), proto).Inc()
+
+	if s.Recorder != nil {
+		s.Recorder.Conn(reused, proto)
+	}
+}
+
+func (s *Session) record(req *http.Request, status int, latency time.Duration, failed bool) {
+	if s.Recorder == nil {
+		return
+	}
+
+	path := req.URL.Path
+
+	if s.Template != nil {
+		path = s.Template(req.URL)
+	}
+
+	s.Recorder.Request(req.Method, path, status, latency, failed)
+}
+
 func (s *Session) SendFormPost(uri string, body map[string]string) (*http.Response, error) {
 
 	data := url.Values{}
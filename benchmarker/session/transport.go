@@ -0,0 +1,78 @@
+package session
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/http2"
+
+	"github.com/yahoojapan/yisucon/benchmarker/config"
+)
+
+// tlsConfig is shared by the transports of every session, see LoadTLS
+var tlsConfig *tls.Config
+
+// LoadTLS prepares the TLS settings of the sessions. caBundle is a PEM file
+// of the CAs trusted for HTTPS targets, the system roots when empty.
+func LoadTLS(caBundle string, skipVerify bool) error {
+	c := &tls.Config{
+		InsecureSkipVerify: skipVerify,
+	}
+
+	if len(caBundle) != 0 {
+		pem, err := ioutil.ReadFile(caBundle)
+		if err != nil {
+			return err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("no certificate found in " + caBundle)
+		}
+
+		c.RootCAs = pool
+	}
+
+	tlsConfig = c
+
+	return nil
+}
+
+// NewTransport returns a keep-alive transport which negotiates HTTP/2 with
+// HTTPS targets unless config.HTTP2 is off
+func NewTransport() *http.Transport {
+	tran := &http.Transport{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 32,
+	}
+
+	if tlsConfig != nil {
+		tran.TLSClientConfig = tlsConfig.Clone()
+	}
+
+	if config.HTTP2 {
+		// fails only on a transport configured twice
+		http2.ConfigureTransport(tran)
+	}
+
+	return tran
+}
+
+// ParseTarget splits a target such as https://example.com:443 into its
+// scheme and host. Targets without a scheme are served over http.
+func ParseTarget(target string) (scheme, host string) {
+	switch {
+	case strings.HasPrefix(target, "https://"):
+		scheme, host = "https", strings.TrimPrefix(target, "https://")
+	case strings.HasPrefix(target, "http://"):
+		scheme, host = "http", strings.TrimPrefix(target, "http://")
+	default:
+		scheme, host = "http", target
+	}
+
+	return scheme, strings.TrimSuffix(host, "/")
+}
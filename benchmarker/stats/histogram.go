@@ -0,0 +1,141 @@
+package stats
+
+import (
+	"math/bits"
+	"time"
+)
+
+const (
+	// subBits sets the precision of the histogram: each power of two range
+	// is split into 2^(subBits-1) buckets, about 3% relative error.
+	subBits  = 5
+	subCount = 1 << subBits
+	subHalf  = subCount >> 1
+)
+
+// Histogram is a log-linear (HDR style) histogram of latencies with
+// microsecond resolution. It is not safe for concurrent use.
+type Histogram struct {
+	counts []int64
+	count  int64
+	sum    time.Duration
+	min    time.Duration
+	max    time.Duration
+}
+
+// Summary is a digest of a Histogram in milliseconds
+type Summary struct {
+	Count int64   `json:"count"`
+	Mean  float64 `json:"mean"`
+	Min   float64 `json:"min"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+	P999  float64 `json:"p999"`
+	Max   float64 `json:"max"`
+}
+
+func NewHistogram() *Histogram {
+	return &Histogram{
+		counts: make([]int64, subCount),
+	}
+}
+
+func bucketIndex(v uint64) int {
+	if v < subCount {
+		return int(v)
+	}
+	shift := bits.Len64(v) - subBits
+	return subCount + (shift-1)*subHalf + int(v>>uint(shift)) - subHalf
+}
+
+func bucketValue(idx int) uint64 {
+	if idx < subCount {
+		return uint64(idx)
+	}
+	k := idx - subCount
+	shift := uint(k/subHalf + 1)
+	m := uint64(k%subHalf + subHalf)
+	// middle of the bucket
+	return m<<shift + (uint64(1)<<shift)/2
+}
+
+// Record adds a latency to the histogram
+func (h *Histogram) Record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+
+	idx := bucketIndex(uint64(d / time.Microsecond))
+
+	if idx >= len(h.counts) {
+		counts := make([]int64, idx+1)
+		copy(counts, h.counts)
+		h.counts = counts
+	}
+
+	h.counts[idx]++
+
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+
+	h.count++
+	h.sum += d
+}
+
+// Count returns the number of recorded values
+func (h *Histogram) Count() int64 {
+	return h.count
+}
+
+// Quantile returns the latency at q (0 <= q <= 1)
+func (h *Histogram) Quantile(q float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+
+	rank := int64(q*float64(h.count-1)) + 1
+
+	var seen int64
+	for idx, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			d := time.Duration(bucketValue(idx)) * time.Microsecond
+			switch {
+			case d > h.max:
+				return h.max
+			case d < h.min:
+				return h.min
+			}
+			return d
+		}
+	}
+
+	return h.max
+}
+
+// Summary returns the digest of the histogram
+func (h *Histogram) Summary() Summary {
+	if h.count == 0 {
+		return Summary{}
+	}
+
+	return Summary{
+		Count: h.count,
+		Mean:  toMillis(h.sum / time.Duration(h.count)),
+		Min:   toMillis(h.min),
+		P50:   toMillis(h.Quantile(0.50)),
+		P90:   toMillis(h.Quantile(0.90)),
+		P99:   toMillis(h.Quantile(0.99)),
+		P999:  toMillis(h.Quantile(0.999)),
+		Max:   toMillis(h.max),
+	}
+}
+
+func toMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
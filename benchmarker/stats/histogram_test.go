@@ -0,0 +1,89 @@
+package stats
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBucketRoundTrip(t *testing.T) {
+	for _, v := range []uint64{0, 1, 31, 32, 33, 63, 64, 100, 999, 1000, 12345, 1 << 20, 1<<40 + 12345} {
+		idx := bucketIndex(v)
+		got := bucketValue(idx)
+
+		if v < subCount {
+			if got != v {
+				t.Errorf("bucketValue(bucketIndex(%d)) = %d, want it exact", v, got)
+			}
+			continue
+		}
+
+		if rel := math.Abs(float64(got)-float64(v)) / float64(v); rel > 1.0/subHalf {
+			t.Errorf("bucketValue(bucketIndex(%d)) = %d, relative error %.3f", v, got, rel)
+		}
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	uniform := make([]time.Duration, 1000)
+	for i := range uniform {
+		uniform[i] = time.Duration(i+1) * time.Millisecond
+	}
+
+	tests := []struct {
+		name   string
+		values []time.Duration
+		q      float64
+		want   time.Duration
+	}{
+		{"empty", nil, 0.5, 0},
+		{"single", []time.Duration{10 * time.Millisecond}, 0.5, 10 * time.Millisecond},
+		{"single p99", []time.Duration{10 * time.Millisecond}, 0.99, 10 * time.Millisecond},
+		{"negative is zero", []time.Duration{-time.Second}, 0.5, 0},
+		{"min", uniform, 0, time.Millisecond},
+		{"p50", uniform, 0.5, 500 * time.Millisecond},
+		{"p90", uniform, 0.9, 900 * time.Millisecond},
+		{"p99", uniform, 0.99, 990 * time.Millisecond},
+		{"max", uniform, 1, 1000 * time.Millisecond},
+		{"outlier", []time.Duration{time.Millisecond, time.Millisecond, time.Millisecond, 5 * time.Second}, 0.5, time.Millisecond},
+		{"outlier max", []time.Duration{time.Millisecond, time.Millisecond, time.Millisecond, 5 * time.Second}, 1, 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHistogram()
+			for _, v := range tt.values {
+				h.Record(v)
+			}
+
+			got := h.Quantile(tt.q)
+
+			// buckets keep about 3% of precision
+			if diff := math.Abs(float64(got - tt.want)); diff > float64(tt.want)*0.035 {
+				t.Errorf("Quantile(%v) = %v, want %v", tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummary(t *testing.T) {
+	h := NewHistogram()
+
+	if got := h.Summary(); got != (Summary{}) {
+		t.Errorf("empty Summary() = %+v, want zero", got)
+	}
+
+	for _, v := range []time.Duration{2 * time.Millisecond, 4 * time.Millisecond, 6 * time.Millisecond} {
+		h.Record(v)
+	}
+
+	got := h.Summary()
+
+	if got.Count != 3 || got.Mean != 4 || got.Min != 2 || got.Max != 6 {
+		t.Errorf("Summary() = %+v, want count 3, mean 4, min 2 and max 6", got)
+	}
+
+	if got.P50 < got.Min || got.P50 > got.P90 || got.P90 > got.P99 || got.P99 > got.P999 || got.P999 > got.Max {
+		t.Errorf("Summary() = %+v, want ordered percentiles", got)
+	}
+}
@@ -0,0 +1,243 @@
+package stats
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Recorder collects per-endpoint latencies and a per-second timeline of
+// a benchmark run. It is safe for concurrent use.
+type Recorder struct {
+	l         *sync.Mutex
+	start     time.Time
+	stopped   bool
+	endpoints map[string]*endpoint
+	timeline  []Bucket
+	conns     Connections
+}
+
+// Connections counts responses by whether their connection was reused and
+// by protocol
+type Connections struct {
+	New       int64            `json:"new"`
+	Reused    int64            `json:"reused"`
+	Protocols map[string]int64 `json:"protocols"`
+}
+
+type endpoint struct {
+	method string
+	path   string
+	errors int64
+	status map[int]int64
+	hist   *Histogram
+}
+
+// Endpoint is the summary of one method and path template
+type Endpoint struct {
+	Method  string        `json:"method"`
+	Path    string        `json:"path"`
+	Errors  int64         `json:"errors"`
+	Status  map[int]int64 `json:"status"`
+	Latency Summary       `json:"latency"`
+}
+
+// Bucket is one second of the timeline
+type Bucket struct {
+	Second        int   `json:"second"`
+	Requests      int64 `json:"requests"`
+	RequestErrors int64 `json:"request_errors"`
+	Actions       int64 `json:"actions"`
+	ActionErrors  int64 `json:"action_errors"`
+	Score         int64 `json:"score"`
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{
+		l:         new(sync.Mutex),
+		start:     time.Now(),
+		endpoints: make(map[string]*endpoint),
+		conns: Connections{
+			Protocols: make(map[string]int64),
+		},
+	}
+}
+
+// Start resets the origin of the timeline
+func (r *Recorder) Start() {
+	defer r.l.Unlock()
+	r.l.Lock()
+	r.start = time.Now()
+	r.timeline = nil
+	r.stopped = false
+}
+
+// Stop freezes the recorder at the end of the bench, the requests and
+// actions that follow are not recorded
+func (r *Recorder) Stop() {
+	defer r.l.Unlock()
+	r.l.Lock()
+	r.stopped = true
+}
+
+// bucket returns the timeline bucket of now. r.l must be held.
+func (r *Recorder) bucket() *Bucket {
+	sec := int(time.Since(r.start) / time.Second)
+	if sec < 0 {
+		sec = 0
+	}
+	for len(r.timeline) <= sec {
+		r.timeline = append(r.timeline, Bucket{
+			Second: len(r.timeline),
+		})
+	}
+	return &r.timeline[sec]
+}
+
+// Request records a single HTTP exchange. status is 0 when no response was received.
+func (r *Recorder) Request(method, path string, status int, latency time.Duration, failed bool) {
+	defer r.l.Unlock()
+	r.l.Lock()
+
+	if r.stopped {
+		return
+	}
+
+	key := method + " " + path
+
+	e, ok := r.endpoints[key]
+	if !ok {
+		e = &endpoint{
+			method: method,
+			path:   path,
+			status: make(map[int]int64),
+			hist:   NewHistogram(),
+		}
+		r.endpoints[key] = e
+	}
+
+	e.status[status]++
+	e.hist.Record(latency)
+
+	b := r.bucket()
+	b.Requests++
+
+	if failed {
+		e.errors++
+		b.RequestErrors++
+	}
+}
+
+// Conn records the connection of a response
+func (r *Recorder) Conn(reused bool, proto string) {
+	defer r.l.Unlock()
+	r.l.Lock()
+
+	if r.stopped {
+		return
+	}
+
+	if reused {
+		r.conns.Reused++
+	} else {
+		r.conns.New++
+	}
+
+	r.conns.Protocols[proto]++
+}
+
+// Connections returns a copy of the connection counts
+func (r *Recorder) Connections() Connections {
+	defer r.l.Unlock()
+	r.l.Lock()
+
+	res := r.conns
+	res.Protocols = make(map[string]int64, len(r.conns.Protocols))
+	for proto, c := range r.conns.Protocols {
+		res.Protocols[proto] = c
+	}
+
+	return res
+}
+
+// Action records the score of a finished checker action
+func (r *Recorder) Action(score int, failed bool) {
+	defer r.l.Unlock()
+	r.l.Lock()
+
+	if r.stopped {
+		return
+	}
+
+	b := r.bucket()
+	b.Actions++
+	b.Score += int64(score)
+
+	if failed {
+		b.ActionErrors++
+	}
+}
+
+// Endpoints returns the per-endpoint summaries keyed by "METHOD path"
+func (r *Recorder) Endpoints() map[string]*Endpoint {
+	defer r.l.Unlock()
+	r.l.Lock()
+
+	res := make(map[string]*Endpoint, len(r.endpoints))
+
+	for key, e := range r.endpoints {
+		status := make(map[int]int64, len(e.status))
+		for code, c := range e.status {
+			status[code] = c
+		}
+		res[key] = &Endpoint{
+			Method:  e.method,
+			Path:    e.path,
+			Errors:  e.errors,
+			Status:  status,
+			Latency: e.hist.Summary(),
+		}
+	}
+
+	return res
+}
+
+// Timeline returns a copy of the per-second buckets
+func (r *Recorder) Timeline() []Bucket {
+	defer r.l.Unlock()
+	r.l.Lock()
+
+	res := make([]Bucket, len(r.timeline))
+	copy(res, r.timeline)
+
+	return res
+}
+
+// WriteTimelineCSV writes buckets as CSV with a header row
+func WriteTimelineCSV(w io.Writer, buckets []Bucket) error {
+	cw := csv.NewWriter(w)
+
+	cw.Write([]string{"second", "requests", "request_errors", "actions", "action_errors", "score"})
+
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].Second < buckets[j].Second
+	})
+
+	for _, b := range buckets {
+		cw.Write([]string{
+			strconv.Itoa(b.Second),
+			strconv.FormatInt(b.Requests, 10),
+			strconv.FormatInt(b.RequestErrors, 10),
+			strconv.FormatInt(b.Actions, 10),
+			strconv.FormatInt(b.ActionErrors, 10),
+			strconv.FormatInt(b.Score, 10),
+		})
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
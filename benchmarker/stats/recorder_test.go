@@ -0,0 +1,131 @@
+package stats
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestRecorderEndpoints(t *testing.T) {
+	r := NewRecorder()
+
+	requests := []struct {
+		method  string
+		path    string
+		status  int
+		latency time.Duration
+		failed  bool
+	}{
+		{"GET", "/", 200, 10 * time.Millisecond, false},
+		{"GET", "/", 200, 30 * time.Millisecond, false},
+		{"GET", "/", 500, 20 * time.Millisecond, true},
+		{"POST", "/login", 302, 5 * time.Millisecond, false},
+		{"GET", "/hashtag/:tag", 0, time.Second, true},
+	}
+
+	for _, req := range requests {
+		r.Request(req.method, req.path, req.status, req.latency, req.failed)
+	}
+
+	tests := []struct {
+		key    string
+		count  int64
+		errors int64
+		status map[int]int64
+		max    float64
+	}{
+		{"GET /", 3, 1, map[int]int64{200: 2, 500: 1}, 30},
+		{"POST /login", 1, 0, map[int]int64{302: 1}, 5},
+		{"GET /hashtag/:tag", 1, 1, map[int]int64{0: 1}, 1000},
+	}
+
+	endpoints := r.Endpoints()
+
+	if len(endpoints) != len(tests) {
+		t.Fatalf("got %d endpoints, want %d", len(endpoints), len(tests))
+	}
+
+	for _, tt := range tests {
+		e, ok := endpoints[tt.key]
+		if !ok {
+			t.Errorf("%s is missing", tt.key)
+			continue
+		}
+		if e.Latency.Count != tt.count || e.Errors != tt.errors || e.Latency.Max != tt.max {
+			t.Errorf("%s : got count %d, errors %d, max %v", tt.key, e.Latency.Count, e.Errors, e.Latency.Max)
+		}
+		for code, c := range tt.status {
+			if e.Status[code] != c {
+				t.Errorf("%s : got %d responses with %d, want %d", tt.key, e.Status[code], code, c)
+			}
+		}
+	}
+}
+
+func TestRecorderTimeline(t *testing.T) {
+	r := NewRecorder()
+	r.Start()
+
+	r.Request("GET", "/", 200, time.Millisecond, false)
+	r.Request("GET", "/", 500, time.Millisecond, true)
+	r.Action(2, false)
+	r.Action(-10, true)
+
+	timeline := r.Timeline()
+
+	if len(timeline) != 1 {
+		t.Fatalf("got %d buckets, want 1", len(timeline))
+	}
+
+	want := Bucket{Second: 0, Requests: 2, RequestErrors: 1, Actions: 2, ActionErrors: 1, Score: -8}
+	if timeline[0] != want {
+		t.Errorf("got %+v, want %+v", timeline[0], want)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := WriteTimelineCSV(buf, []Bucket{{Second: 1, Requests: 3}, want}); err != nil {
+		t.Fatal(err)
+	}
+
+	csv := "second,requests,request_errors,actions,action_errors,score\n0,2,1,2,1,-8\n1,3,0,0,0,0\n"
+	if buf.String() != csv {
+		t.Errorf("WriteTimelineCSV() = %q, want %q", buf.String(), csv)
+	}
+}
+
+func TestRecorderStop(t *testing.T) {
+	r := NewRecorder()
+	r.Start()
+
+	r.Request("GET", "/", 200, time.Millisecond, false)
+	r.Action(1, false)
+	r.Conn(false, "HTTP/1.1")
+
+	r.Stop()
+
+	// the traffic after the bench, such as the verification
+	r.Request("GET", "/", 200, time.Millisecond, false)
+	r.Request("POST", "/", 500, time.Millisecond, true)
+	r.Action(2, false)
+	r.Conn(true, "HTTP/1.1")
+
+	if e := r.Endpoints(); len(e) != 1 || e["GET /"].Latency.Count != 1 {
+		t.Errorf("a stopped recorder recorded requests : %+v", e)
+	}
+
+	want := []Bucket{{Second: 0, Requests: 1, Actions: 1, Score: 1}}
+	if got := r.Timeline(); len(got) != 1 || got[0] != want[0] {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if c := r.Connections(); c.New != 1 || c.Reused != 0 {
+		t.Errorf("a stopped recorder counted connections : %+v", c)
+	}
+
+	r.Start()
+	r.Action(3, false)
+
+	if got := r.Timeline(); len(got) != 1 || got[0].Actions != 1 {
+		t.Errorf("Start() did not resume the recording : %+v", got)
+	}
+}
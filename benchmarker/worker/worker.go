@@ -4,22 +4,32 @@ import (
 	"container/ring"
 	"context"
 	"errors"
+	"math/rand"
 	"sync"
 
 	"github.com/yahoojapan/yisucon/benchmarker/checker"
 	"github.com/yahoojapan/yisucon/benchmarker/data"
+	"github.com/yahoojapan/yisucon/benchmarker/logger"
 	"github.com/yahoojapan/yisucon/benchmarker/model"
 	"github.com/yahoojapan/yisucon/benchmarker/score"
+	"github.com/yahoojapan/yisucon/benchmarker/stats"
 )
 
 type Worker struct {
-	Account *model.Account
-	Host    string
+	Account  *model.Account
+	Host     string
+	Recorder *stats.Recorder
+	// Seed derives the random source of each run of the worker
+	Seed    int64
+	Log     *logger.Logger
+	Journal *checker.Journal
 }
 
-func NewWorkers(host string) (*ring.Ring, error) {
+// NewWorkers returns one worker per account. Accounts and each worker's
+// seed are derived from seed so that runs can be replayed.
+func NewWorkers(host string, rec *stats.Recorder, seed int64, l *logger.Logger) (*ring.Ring, error) {
 
-	accounts, err := data.GetAccounts()
+	accounts, err := data.GetAccounts(rand.New(rand.NewSource(seed)))
 
 	if err != nil {
 		return nil, err
@@ -27,10 +37,13 @@ func NewWorkers(host string) (*ring.Ring, error) {
 
 	r := ring.New(len(accounts))
 
-	for _, account := range accounts {
+	for i, account := range accounts {
 		r.Value = &Worker{
-			Account: account,
-			Host:    host,
+			Account:  account,
+			Host:     host,
+			Recorder: rec,
+			Seed:     seed + int64(i) + 1,
+			Log:      l.With("worker", account.Name),
 		}
 		r = r.Next()
 	}
@@ -38,12 +51,19 @@ func NewWorkers(host string) (*ring.Ring, error) {
 	return r, nil
 }
 
-func (w *Worker) Run(parent context.Context, sc chan score.Score) error {
+// Run plays the default scenario once. run numbers the runs of a processor,
+// each run gets its own random source derived from it and the worker's seed
+// since the runs of a worker may overlap.
+func (w *Worker) Run(parent context.Context, sc chan score.Score, run int64) error {
 
 	ctx, cancel := context.WithCancel(parent)
 	defer cancel()
 
-	c := checker.NewChecker(ctx, w.Host, w.Account)
+	rnd := rand.New(rand.NewSource(runSeed(w.Seed, run)))
+
+	c := checker.NewChecker(ctx, w.Host, w.Account, w.Recorder, rnd)
+	c.Logger = w.Log
+	c.Journal = w.Journal
 	defer c.Close()
 
 	scenario := checker.NewDefaultScenario(c)
@@ -70,9 +90,15 @@ func (w *Worker) Run(parent context.Context, sc chan score.Score) error {
 			}
 			wg.Add(1)
 			go func(action *checker.Action) {
-				res <- score.CalcScore(action.Method, action.Name, action.Action)
+				res <- c.Run(action)
 				wg.Done()
 			}(scenario.Pop())
 		}
 	}
 }
+
+// runSeed mixes run into seed with the golden ratio constant so that nearby
+// seeds and runs do not replay the same sequence
+func runSeed(seed, run int64) int64 {
+	return seed ^ int64(uint64(run)*0x9E3779B97F4A7C15)
+}
@@ -31,7 +31,7 @@ export class HomeComponent implements OnInit, OnDestroy {
   public isLoggedIn = false;
   public isInSession = false;
   private isInQueue = false;
-  public queues = {running: [], waiting: []};
+  public queues = {running: [], waiting: [], aborted: []};
   public disabledRunBenchmarker = true;
   public scores: Score[];
   public splineOptions: Object;
@@ -245,6 +245,12 @@ export class HomeComponent implements OnInit, OnDestroy {
         this.isInQueue = true;
       }
     }
+    data.aborted = data.aborted || [];
+    for (let i of data.aborted) {
+      if (i.team_id === this.session.get('team_id')) {
+        i.self = true;
+      }
+    }
     this.queues = data;
   }
 
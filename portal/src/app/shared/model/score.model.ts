@@ -1,6 +1,21 @@
+// 失敗したリクエスト(ベンチマーカーのreport.Entry)
+export interface TranscriptEntry {
+  at: number;
+  action: string;
+  method: string;
+  url?: string;
+  status?: number;
+  snippet?: string;
+  elapsed_ms: number;
+  error: string;
+  kind: string;
+  selector?: string;
+}
+
 export class Score {
   private date: Date;
-  constructor(public score: number, public message: string, date: string) {
+  constructor(public score: number, public message: string, date: string,
+              public transcript: TranscriptEntry[] = [], public rulesVersion: string = '') {
     this.date = new Date(date);
   }
 
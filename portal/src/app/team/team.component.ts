@@ -8,7 +8,7 @@ import 'rxjs/add/operator/switchMap';
 import { Team } from '../shared/model/team.model';
 import { SessionService } from '../shared/session.service';
 import { ModelService } from '../shared/model/model.service';
-import { Score } from '../shared/model/score.model';
+import { Score, TranscriptEntry } from '../shared/model/score.model';
 
 
 @Component({
@@ -49,7 +49,7 @@ export class TeamComponent implements OnInit {
         });
 
       this.model.get(`/api/scores/${id}`)
-        .subscribe((data: { id: number; score: number; message: string; date: string }[]) => {
+        .subscribe((data: { id: number; score: number; message: string; transcript: TranscriptEntry[]; rulesVersion: string; date: string }[]) => {
           this.graphOptions = {
             size: { height: 400 },
             axis: {
@@ -104,7 +104,7 @@ export class TeamComponent implements OnInit {
           };
 
           if (this.isOwnTeam()) {
-            this.scores = data.map((s) => new Score(s.score, s.message, s.date));
+            this.scores = data.map((s) => new Score(s.score, s.message, s.date, s.transcript, s.rulesVersion));
           }
         });
     });
@@ -136,8 +136,8 @@ apiRouter.get('/scores/:team_id', (req, res) => {
   let id = parseInt(req.params.team_id, 10);
 
   let conn;
-  const sql = `SELECT score.id AS score_id, score.score AS score_score, score.message AS score_message, score.date AS score_date FROM score
-  INNER JOIN queue ON score.queue_id = queue.id where queue.team_id = ? ORDER BY score.date DESC`;
+  const sql = `SELECT score.id AS score_id, score.score AS score_score, score.message AS score_message, score.transcript AS score_transcript,
+  score.rules_version AS score_rules_version, score.date AS score_date FROM score INNER JOIN queue ON score.queue_id = queue.id where queue.team_id = ? ORDER BY score.date DESC`;
 
   Observable.bindNodeCallback(pool.getConnection.bind(pool))()
     .do((c: IConnection) => { conn = c; })
@@ -147,8 +147,10 @@ apiRouter.get('/scores/:team_id', (req, res) => {
       return query(sql, [id]);
     })
     .mergeMap((result) => { return result[0]; })
-    .scan((acc, row: {score_id: number; score_score: number; score_message: string; score_date: string}) => {
-      acc.push({id: row.score_id, score: row.score_score, message: row.score_message, date: row.score_date });
+    .scan((acc, row: {score_id: number; score_score: number; score_message: string; score_transcript: string; score_rules_version: string; score_date: string}) => {
+      acc.push({id: row.score_id, score: row.score_score, message: row.score_message,
+        transcript: row.score_transcript ? JSON.parse(row.score_transcript) : [], rulesVersion: row.score_rules_version,
+        date: row.score_date });
       return acc;
     }, [])
     .last()
@@ -469,7 +471,7 @@ apiRouter.post('/benches/:team_id', (req, res) => {
 
 apiRouter.get('/queues', (req, res) => {
   let conn: IConnection;
-  let sqlSelect = `SELECT team_id, team.name AS team_name, status FROM queue JOIN team ON queue.team_id = team.id WHERE status <> 0 ORDER BY date`;
+  let sqlSelect = `SELECT team_id, team.name AS team_name, status, reason FROM queue JOIN team ON queue.team_id = team.id WHERE status IN (1, 2, 3) ORDER BY date`;
 
   Observable.bindNodeCallback(pool.getConnection.bind(pool))()
     .do((c: IConnection) => { conn = c; })
@@ -479,14 +481,17 @@ apiRouter.get('/queues', (req, res) => {
       return query(sqlSelect);
     })
     .mergeMap((result) => { return result[0]; })
-    .scan((acc, row: {team_id: number; team_name: string; status: number;}) => {
-      if (row.status === 2) {
+    .scan((acc, row: {team_id: number; team_name: string; status: number; reason: string;}) => {
+      // 中断されたジョブは理由と一緒に再登録されるまで表示する
+      if (row.status === 3) {
+        acc.aborted.push(row);
+      } else if (row.status === 2) {
         acc.running.push(row);
       } else {
         acc.waiting.push(row);
       }
       return acc;
-    }, {running: [], waiting: []})
+    }, {running: [], waiting: [], aborted: []})
     .last()
     .finally(() => { conn.release(); })
     .subscribe(
@@ -497,7 +502,7 @@ apiRouter.get('/queues', (req, res) => {
         let code = 500, json = {};
         if (err.name === 'EmptyError') {
           code = 200;
-          json = {running: [], waiting: []};
+          json = {running: [], waiting: [], aborted: []};
         }
 
         conn.rollback(() => {});
@@ -510,8 +515,8 @@ apiRouter.post('/queues', [validateJsonSchema({body: postQueueSchema}), validate
   let conn: IConnection;
   let teamId = req.body.team_id;
   let sqlSelectHost = `SELECT host FROM team WHERE id = ?`;
-  let sqlInsert = `INSERT INTO queue (team_id, status) VALUES (?, 0) ON DUPLICATE KEY UPDATE status = 1`;
-  let sqlSelect = `SELECT team_id, team.name AS team_name, status FROM queue JOIN team ON queue.team_id = team.id WHERE status <> 0 ORDER BY date`;
+  let sqlInsert = `INSERT INTO queue (team_id, status) VALUES (?, 0) ON DUPLICATE KEY UPDATE status = 1, attempts = 0, reason = NULL`;
+  let sqlSelect = `SELECT team_id, team.name AS team_name, status, reason FROM queue JOIN team ON queue.team_id = team.id WHERE status IN (1, 2, 3) ORDER BY date`;
 
   if (req.auth.id !== teamId) {
     let err = new Error('Invalid team id');
@@ -550,14 +555,17 @@ apiRouter.post('/queues', [validateJsonSchema({body: postQueueSchema}), validate
       return query(sqlSelect);
     })
     .mergeMap((result) => { return result[0]; })
-    .scan((acc, row: {team_id: number; team_name: string; status: number;}) => {
-      if (row.status === 2) {
+    .scan((acc, row: {team_id: number; team_name: string; status: number; reason: string;}) => {
+      // 中断されたジョブは理由と一緒に再登録されるまで表示する
+      if (row.status === 3) {
+        acc.aborted.push(row);
+      } else if (row.status === 2) {
         acc.running.push(row);
       } else {
         acc.waiting.push(row);
       }
       return acc;
-    }, {running: [], waiting: []})
+    }, {running: [], waiting: [], aborted: []})
     .last()
     .finally(() => { conn ? conn.release() : null; })
     .subscribe(
@@ -583,6 +591,158 @@ apiRouter.post('/queues', [validateJsonSchema({body: postQueueSchema}), validate
     );
 });
 
+/**
+ * ベンチマーカー用キューAPI (benchmarker の job_source = http)
+ * GET /queue/next?worker=&lease=&max_attempts= 待機中のジョブを1件確保する。なければ204
+ * POST /queue/:queue_id/heartbeat?worker=&lease= リースを延長する。失効していれば409
+ * POST /queue/:queue_id/result?worker= 結果を保存してジョブを終了する。失効していれば409
+ *
+ * benchmarker/db の FetchQueue, RenewLease, SaveResult と同じリースの扱い
+ */
+const QUEUE_DONE = 0, QUEUE_STANDBY = 1, QUEUE_RUNNING = 2, QUEUE_ABORTED = 3;
+const QUEUE_EXPIRED = `status = ? AND COALESCE(lease_expires, date + INTERVAL 2 MINUTE) < NOW()`;
+
+apiRouter.get('/queue/next', (req, res) => {
+  let conn: IConnection;
+  let job;
+  const worker = req.query.worker;
+  const lease = parseInt(req.query.lease, 10) || 120;
+  const maxAttempts = parseInt(req.query.max_attempts, 10) || 3;
+  const reason = `aborted : the benchmarker stopped responding ${maxAttempts} times`;
+
+  const sqlAbortScore = `INSERT INTO score (queue_id, score, message) SELECT id, 0, ? FROM queue WHERE ${QUEUE_EXPIRED} AND attempts >= ?`;
+  const sqlAbort = `UPDATE queue SET status = ?, worker_id = NULL, lease_expires = NULL, reason = ? WHERE ${QUEUE_EXPIRED} AND attempts >= ?`;
+  const sqlRetry = `UPDATE queue SET status = ?, worker_id = NULL, lease_expires = NULL, reason = ?, date = date WHERE ${QUEUE_EXPIRED} AND attempts < ?`;
+  const sqlSelect = `SELECT * FROM team_queue WHERE status = ? ORDER BY date LIMIT 1`;
+  const sqlClaim = `UPDATE queue SET status = ?, worker_id = ?, lease_expires = NOW() + INTERVAL ? SECOND, attempts = attempts + 1
+  WHERE id = ? AND status = ?`;
+
+  if (!worker) {
+    return res.status(400).json({statusText: 'Bad Request'});
+  }
+
+  Observable.bindNodeCallback(pool.getConnection.bind(pool))()
+    .do((c: IConnection) => { conn = c; })
+    .mergeMap(() => {
+      return Observable.bindNodeCallback(conn.beginTransaction.bind(conn))();
+    })
+    .mergeMap(() => {
+      // TODO: refactor bindした場合の型定義の扱い
+      let query = Observable.bindNodeCallback(conn.query.bind(conn)) as any;
+      return query(sqlAbortScore, [reason, QUEUE_RUNNING, maxAttempts])
+        .mergeMap(() => { return query(sqlAbort, [QUEUE_ABORTED, reason, QUEUE_RUNNING, maxAttempts]); })
+        .mergeMap(() => { return query(sqlRetry, [QUEUE_STANDBY, 'lease expired : retrying', QUEUE_RUNNING, maxAttempts]); })
+        .mergeMap(() => { return query(sqlSelect, [QUEUE_STANDBY]); })
+        .mergeMap((result) => {
+          if (result[0].length === 0) {
+            return Observable.of(null);
+          }
+          let row = result[0][0];
+          job = {team_id: row.team_id, queue_id: row.queue_id, host: row.host, attempts: row.attempts + 1, locale: row.locale || undefined};
+          return query(sqlClaim, [QUEUE_RUNNING, worker, lease, row.queue_id, QUEUE_STANDBY]);
+        });
+    })
+    .mergeMap((result) => {
+      // another benchmarker claimed the job first
+      if (result && result[0].affectedRows !== 1) {
+        job = null;
+      }
+      return Observable.bindNodeCallback(conn.commit.bind(conn))();
+    })
+    .finally(() => { conn ? conn.release() : null; })
+    .subscribe(
+      () => {
+        if (job) {
+          res.json(job);
+        } else {
+          res.status(204).end();
+        }
+      },
+      (err) => {
+        console.log(err);
+        conn ? conn.rollback(() => {}) : null;
+        res.status(500).json({name: 'SQLError', message: 'SQLError'});
+      }
+    );
+});
+
+apiRouter.post('/queue/:queue_id/heartbeat', (req, res) => {
+  const queueId = parseInt(req.params.queue_id, 10);
+  const lease = parseInt(req.query.lease, 10) || 120;
+  const sqlRenew = `UPDATE queue SET lease_expires = NOW() + INTERVAL ? SECOND, date = date WHERE id = ? AND status = ? AND worker_id = ?`;
+
+  // TODO: refactor bindした場合の型定義の扱い
+  let query = Observable.bindNodeCallback(pool.query.bind(pool)) as any;
+
+  query(sqlRenew, [lease, queueId, QUEUE_RUNNING, req.query.worker])
+    .subscribe(
+      (result) => {
+        if (result[0].affectedRows !== 1) {
+          res.status(409).json({statusText: 'Lease lost'});
+        } else {
+          res.json({status: 'ok'});
+        }
+      },
+      (err) => {
+        console.log(err);
+        res.status(500).json({name: 'SQLError', message: 'SQLError'});
+      }
+    );
+});
+
+apiRouter.post('/queue/:queue_id/result', (req, res) => {
+  let conn: IConnection;
+  const queueId = parseInt(req.params.queue_id, 10);
+  const result = req.body;
+  const report = result.report ? JSON.stringify(result.report, null, 2) : null;
+  const transcript = result.report ? JSON.stringify(result.report.transcript || []) : null;
+
+  const sqlDone = `UPDATE queue SET status = ?, worker_id = NULL, lease_expires = NULL, reason = NULL
+  WHERE id = ? AND team_id = ? AND status = ? AND worker_id = ?`;
+  const sqlInsert = `INSERT INTO score (queue_id, score, message, status, reason, config, report, transcript, rules_version)
+  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`;
+
+  Observable.bindNodeCallback(pool.getConnection.bind(pool))()
+    .do((c: IConnection) => { conn = c; })
+    .mergeMap(() => {
+      return Observable.bindNodeCallback(conn.beginTransaction.bind(conn))();
+    })
+    .mergeMap(() => {
+      // TODO: refactor bindした場合の型定義の扱い
+      let query = Observable.bindNodeCallback(conn.query.bind(conn)) as any;
+
+      // only the benchmarker holding the lease may close the job
+      return query(sqlDone, [QUEUE_DONE, queueId, result.team_id, QUEUE_RUNNING, req.query.worker])
+        .mergeMap((done) => {
+          if (done[0].affectedRows !== 1) {
+            let err = new Error('Lease lost');
+            err.name = 'LeaseLostError';
+            throw err;
+          }
+          return query(sqlInsert, [queueId, result.score, result.message, result.status, result.reason || null,
+            result.config, report, transcript, result.rules_version || null]);
+        });
+    })
+    .mergeMap(() => {
+      return Observable.bindNodeCallback(conn.commit.bind(conn))();
+    })
+    .finally(() => { conn ? conn.release() : null; })
+    .subscribe(
+      () => {
+        res.json({status: 'ok'});
+      },
+      (err) => {
+        conn ? conn.rollback(() => {}) : null;
+        if (err.name === 'LeaseLostError') {
+          res.status(409).json({statusText: 'Lease lost'});
+        } else {
+          console.log(err);
+          res.status(500).json({name: 'SQLError', message: 'SQLError'});
+        }
+      }
+    );
+});
+
 const setupExpressJsonSchema = (err, req, res, next) => {
   if (err.name === 'JsonSchemaValidation') {
     // Log the error however you please
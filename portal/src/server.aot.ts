@@ -51,7 +51,8 @@ app.set('views', __dirname);
 app.set('view engine', 'html');
 app.set('json spaces', 2);
 app.use(cookieParser('Angular 2 Universal'));
-app.use(bodyParser.json());
+// benchmark results carry their whole report
+app.use(bodyParser.json({limit: '16mb'}));
 app.use(compression());
 
 const accessLogStream = fs.createWriteStream(ROOT + '/morgan.log', {flags: 'a'});
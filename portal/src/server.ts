@@ -52,7 +52,8 @@ app.set('json spaces', 2);
 
 // Our API for demos only
 app.use(cookieParser('Angular 2 Universal'));
-app.use(bodyParser.json());
+// benchmark results carry their whole report
+app.use(bodyParser.json({limit: '16mb'}));
 app.use(compression());
 app.use(morgan('dev'));
 